@@ -24,7 +24,7 @@ func main() {
 	flag.Parse()
 	cmd := flag.Arg(0)
 
-	j, err := journal.Open(*fname)
+	j, err := journal.Open(*fname, journal.OpenOptions{})
 	must(err)
 
 	switch {
@@ -10,10 +10,34 @@ import (
 	"time"
 
 	"github.com/abourget/ledger/journal"
+	"github.com/abourget/ledger/period"
+	"github.com/abourget/ledger/prices"
+	"github.com/abourget/ledger/tools/filter"
 	"github.com/abourget/ledger/tools/reports"
 )
 
 var fname = flag.String("f", "", "ledger file")
+var strict = flag.Bool("strict", false, "warn about undeclared accounts and commodities")
+var pedantic = flag.Bool("pedantic", false, "fail on undeclared accounts and commodities")
+var decimalComma = flag.Bool("decimal-comma", false, "read quantities like 1.234,56, unless their commodity has a format")
+var periodExpr = flag.String("p", "", "only consider transactions in period, like 'last month' or 'from 2024/01 to 2024/06'")
+
+var market = flag.Bool("V", false, "balance, register: value amounts at their market price")
+var exchange = flag.String("X", "", "balance, register: value amounts in this commodity")
+
+var depth = flag.Int("depth", 0, "balance: only show accounts down to this depth")
+var flat = flag.Bool("flat", false, "balance: show full account names rather than a tree")
+var empty = flag.Bool("empty", false, "balance: show accounts with a zero balance")
+var noTotal = flag.Bool("no-total", false, "balance: do not show the final total")
+
+var related = flag.Bool("related", false, "register: show the other postings of the matching transactions")
+var subtotal = flag.Bool("subtotal", false, "register: show one subtotal per account")
+var payeeWidth = flag.Int("payee-width", 0, "register: width of the payee column")
+var accountWidth = flag.Int("account-width", 0, "register: width of the account column")
+var amountWidth = flag.Int("amount-width", 0, "register: width of the amount column")
+var totalWidth = flag.Int("total-width", 0, "register: width of the running total column")
+
+var lotMethod = flag.String("lot-method", "fifo", "gains, taxlots: lots sold first when a sale does not designate them, 'fifo' or 'lifo'")
 
 func must(err error) {
 	if err != nil {
@@ -21,6 +45,47 @@ func must(err error) {
 	}
 }
 
+// transactions returns the journal's transactions, limited by -p.
+func transactions(j *journal.Journal) ([]*journal.Transaction, error) {
+	txs, err := j.Transactions()
+	if err != nil || *periodExpr == "" {
+		return txs, err
+	}
+	inPeriod, err := filter.Period(*periodExpr)
+	if err != nil {
+		return nil, err
+	}
+	return filter.New(txs, inPeriod).Slice(), nil
+}
+
+// priceDB returns the price history db, when -V or -X ask for it.
+func priceDB(db *prices.PriceDB, err error) *prices.PriceDB {
+	if !*market && *exchange == "" {
+		return nil
+	}
+	must(err)
+	return db
+}
+
+// accountFilter returns a case-insensitive account filter, from the
+// regexp given after the command.
+func accountFilter() func(account string) bool {
+	re := regexp.MustCompile("(?i)" + flag.Arg(1))
+	return re.MatchString
+}
+
+// lotTrackingMethod returns the method given with -lot-method.
+func lotTrackingMethod() journal.LotMethod {
+	switch *lotMethod {
+	case "fifo":
+		return journal.FIFO
+	case "lifo":
+		return journal.LIFO
+	}
+	log.Fatalln("unknown lot method:", *lotMethod)
+	return 0
+}
+
 func main() {
 	flag.Parse()
 	cmd := flag.Arg(0)
@@ -33,19 +98,82 @@ func main() {
 		}
 	}
 
-	j, err := journal.Open(*fname)
+	j, err := journal.Open(*fname, journal.OpenOptions{Strict: *strict, Pedantic: *pedantic, DecimalComma: *decimalComma})
+	if errs, ok := err.(journal.ErrorList); ok {
+		for _, e := range errs {
+			fmt.Fprintln(os.Stderr, e)
+		}
+		os.Exit(1)
+	}
 	must(err)
+	for _, warning := range j.Warnings {
+		fmt.Fprintln(os.Stderr, "warning:", warning)
+	}
+	// The prices are used by the `market` function of value expressions.
+	// Their errors only stop the commands showing values.
+	history, historyErr := prices.FromJournal(j)
+	if historyErr == nil {
+		j.SetPrices(history)
+	}
 
 	switch {
 	case cmd == "balance" || cmd == "bal":
-		filter := regexp.MustCompile("(?i)" + flag.Arg(1))
-
-		txs, err := j.Transactions()
+		txs, err := transactions(j)
+		must(err)
+		bal := reports.BalanceWith(txs, reports.BalanceOptions{
+			Filter:   accountFilter(),
+			Market:   *market,
+			Exchange: *exchange,
+			Prices:   priceDB(history, historyErr),
+			Styles:   j.Styles(),
+		})
+		must(bal.PrintOptions(os.Stdout, reports.BalancePrintOptions{
+			Depth:   *depth,
+			Tree:    !*flat,
+			Empty:   *empty,
+			NoTotal: *noTotal,
+		}))
+	case cmd == "register" || cmd == "reg":
+		txs, err := transactions(j)
 		must(err)
-		bal := reports.BalanceFiltered(txs, func(acc string) bool {
-			return filter.MatchString(acc)
+		reg, err := reports.Register(txs, reports.RegisterOptions{
+			Filter:       accountFilter(),
+			Related:      *related,
+			Subtotal:     *subtotal,
+			Market:       *market,
+			Exchange:     *exchange,
+			Prices:       priceDB(history, historyErr),
+			Styles:       j.Styles(),
+			PayeeWidth:   *payeeWidth,
+			AccountWidth: *accountWidth,
+			AmountWidth:  *amountWidth,
+			TotalWidth:   *totalWidth,
 		})
-		must(bal.Print(os.Stdout))
+		must(err)
+		must(reg.Print(os.Stdout))
+	case cmd == "gains":
+		lots, err := j.Lots(lotTrackingMethod())
+		must(err)
+		must(historyErr)
+		must(reports.Gains(lots, reports.GainsOptions{Filter: accountFilter(), Prices: history}).Print(os.Stdout))
+	case cmd == "taxlots":
+		lots, err := j.Lots(lotTrackingMethod())
+		must(err)
+		opts := reports.TaxLotOptions{Filter: accountFilter()}
+		if *periodExpr != "" {
+			opts.Period, err = period.Parse(*periodExpr)
+			must(err)
+		}
+		must(reports.TaxLots(lots, opts).WriteCSV(os.Stdout))
+	case cmd == "validate":
+		errs, err := j.Validate()
+		must(err)
+		for _, e := range errs {
+			fmt.Println(e)
+		}
+		if len(errs) != 0 {
+			os.Exit(1)
+		}
 	case cmd == "testadd":
 		tx := j.AddTransaction(time.Now(), "This is a test transaction")
 		tx.NewPosting("Expenses:Testing").SetAmount("EUR", 120)
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/abourget/ledger/journal"
+	"github.com/abourget/ledger/parse"
+	"github.com/abourget/ledger/print"
+)
+
+// document is an open ledger file, parsed with all its errors.
+type document struct {
+	uri         string
+	text        string
+	tree        *parse.Tree
+	journal     *journal.Journal
+	parseErrors parse.ErrorList
+}
+
+func newDocument(uri, text string) *document {
+	d := &document{uri: uri, text: text}
+	d.tree = parse.New(filename(uri), text)
+	d.tree.Mode = parse.AllErrors
+	if errs, ok := d.tree.Parse().(parse.ErrorList); ok {
+		d.parseErrors = errs
+	}
+	d.journal = journal.NewFromTree(d.tree)
+	return d
+}
+
+// filename returns the path of a file:// URI, or the URI itself.
+func filename(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return u.Path
+}
+
+// position converts a byte offset in the text to an LSP position.
+func (d *document) position(offset parse.Pos) position {
+	line, col := d.tree.LineCol(offset)
+	lineStart := int(offset) - (col - 1)
+	return position{Line: line - 1, Character: utf16Len(d.text[lineStart:offset])}
+}
+
+// offset converts an LSP position to a byte offset in the text.
+func (d *document) offset(p position) parse.Pos {
+	start := 0
+	for line := 0; line < p.Line; line++ {
+		i := strings.IndexByte(d.text[start:], '\n')
+		if i < 0 {
+			return parse.Pos(len(d.text))
+		}
+		start += i + 1
+	}
+	units := 0
+	for i, r := range d.text[start:] {
+		if units >= p.Character || r == '\n' {
+			return parse.Pos(start + i)
+		}
+		units += len(utf16.Encode([]rune{r}))
+	}
+	return parse.Pos(len(d.text))
+}
+
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += len(utf16.Encode([]rune{r}))
+	}
+	return n
+}
+
+// firstLineRange returns the range of a span, limited to its first
+// line so multi-line nodes, like transactions, are not entirely
+// underlined.
+func (d *document) firstLineRange(span parse.Span) lspRange {
+	end := span.End
+	if i := strings.IndexByte(d.text[span.Start:end], '\n'); i >= 0 {
+		end = span.Start + parse.Pos(i)
+	}
+	return lspRange{d.position(span.Start), d.position(end)}
+}
+
+func (d *document) diagnostics() []diagnostic {
+	diags := []diagnostic{}
+	for _, err := range d.parseErrors {
+		start := parse.Pos(err.Offset)
+		end := start
+		if int(end) < len(d.text) && d.text[end] != '\n' {
+			_, w := utf8.DecodeRuneInString(d.text[end:])
+			end += parse.Pos(w)
+		}
+		diags = append(diags, diagnostic{
+			Range:    lspRange{d.position(start), d.position(end)},
+			Severity: severityError,
+			Source:   "ledger",
+			Message:  err.Msg,
+		})
+	}
+
+	errs, err := d.journal.Validate()
+	if err != nil {
+		return diags
+	}
+	for _, e := range errs {
+		// Errors in the included journals are located in other files.
+		if e.Node == nil || !strings.HasPrefix(e.Location, d.tree.FileName+":") {
+			continue
+		}
+		diags = append(diags, diagnostic{
+			Range:    d.firstLineRange(e.Node.Span()),
+			Severity: severityError,
+			Source:   "ledger",
+			Message:  e.Msg,
+		})
+	}
+	return diags
+}
+
+// eachPosting calls fn for each posting of the document, with the
+// transaction holding it, if it is a plain transaction.
+func (d *document) eachPosting(fn func(p *parse.PostingNode, x *parse.XactNode)) {
+	for _, n := range d.tree.Root.Nodes {
+		switch n := n.(type) {
+		case *parse.XactNode:
+			for _, p := range n.Postings {
+				fn(p, n)
+			}
+		case *parse.AutoXactNode:
+			for _, p := range n.Postings {
+				fn(p, nil)
+			}
+		case *parse.PeriodicXactNode:
+			for _, p := range n.Postings {
+				fn(p, nil)
+			}
+		}
+	}
+}
+
+// accountSpan returns the span of the account name of a posting.
+func (d *document) accountSpan(p *parse.PostingNode) parse.Span {
+	start := p.Pos
+	if i := strings.Index(d.text[p.Pos:p.End], p.Account); i >= 0 {
+		start += parse.Pos(i)
+	}
+	return parse.Span{Start: start, End: start + parse.Pos(len(p.Account))}
+}
+
+func contains(span parse.Span, offset parse.Pos) bool {
+	return span.Start <= offset && offset <= span.End
+}
+
+func (d *document) completion(pos position) completionList {
+	offset := d.offset(pos)
+	lineStart := strings.LastIndexByte(d.text[:offset], '\n') + 1
+	prefix := d.text[lineStart:offset]
+
+	list := completionList{Items: []completionItem{}}
+	switch {
+	case strings.HasPrefix(prefix, " ") || strings.HasPrefix(prefix, "\t"):
+		// Posting line: complete the account, until the spaces
+		// separating it from the amount.
+		account := strings.TrimLeft(prefix, " \t*!")
+		if strings.Contains(account, "  ") || strings.Contains(account, "\t") || strings.HasPrefix(account, ";") {
+			return list
+		}
+		for _, name := range d.accounts() {
+			list.Items = append(list.Items, completionItem{Label: name, Kind: completionKindModule, Detail: "account"})
+		}
+	case len(prefix) > 0 && prefix[0] >= '0' && prefix[0] <= '9' && strings.ContainsAny(prefix, " \t"):
+		// Transaction line, past the date: complete the payee.
+		if strings.Contains(prefix, ";") {
+			return list
+		}
+		for _, payee := range d.payees() {
+			list.Items = append(list.Items, completionItem{Label: payee, Kind: completionKindValue, Detail: "payee"})
+		}
+	}
+	return list
+}
+
+// accounts returns the declared and used account names, sorted.
+func (d *document) accounts() []string {
+	seen := make(map[string]bool)
+	for _, n := range d.tree.Root.Nodes {
+		if a, ok := n.(*parse.AccountNode); ok {
+			seen[a.Account] = true
+		}
+	}
+	d.eachPosting(func(p *parse.PostingNode, _ *parse.XactNode) {
+		seen[d.journal.Account(p)] = true
+	})
+	return sortedKeys(seen)
+}
+
+// payees returns the declared payees and the payees of the
+// transactions, sorted.
+func (d *document) payees() []string {
+	seen := make(map[string]bool)
+	for _, n := range d.tree.Root.Nodes {
+		switch n := n.(type) {
+		case *parse.PayeeNode:
+			seen[n.Payee] = true
+		case *parse.XactNode:
+			if n.Description != "" {
+				seen[n.Description] = true
+			}
+		}
+	}
+	return sortedKeys(seen)
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		if k != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// hover shows the running balance of the account of the posting under
+// the cursor, right after that posting, in date order.
+func (d *document) hover(pos position) *hover {
+	offset := d.offset(pos)
+	var target *parse.PostingNode
+	d.eachPosting(func(p *parse.PostingNode, x *parse.XactNode) {
+		if x != nil && contains(p.Span(), offset) {
+			target = p
+		}
+	})
+	if target == nil {
+		return nil
+	}
+
+	balance, ok := d.runningBalance(target)
+	if !ok {
+		return nil
+	}
+	account := d.journal.Account(target)
+	r := d.firstLineRange(d.accountSpan(target))
+	return &hover{
+		Contents: markupContent{
+			Kind:  "markdown",
+			Value: fmt.Sprintf("**%s**\n\nRunning balance: %s", account, balance),
+		},
+		Range: &r,
+	}
+}
+
+// runningBalance returns the balance of the account of posting `target`
+// once it is accounted for.  It is false if amounts cannot be computed,
+// like when value expressions are invalid.
+func (d *document) runningBalance(target *parse.PostingNode) (string, bool) {
+	txs, err := d.journal.Transactions()
+	if err != nil {
+		return "", false
+	}
+	sort.SliceStable(txs, func(i, j int) bool {
+		return txs[i].Node.Date.Before(txs[j].Node.Date)
+	})
+
+	account := d.journal.Account(target)
+	totals := make(map[string]*big.Rat)
+	for _, tx := range txs {
+		for _, p := range tx.Postings() {
+			if p.Account() != account {
+				continue
+			}
+			a := p.Amount()
+			if a == nil {
+				return "", false
+			}
+			if totals[a.Commodity] == nil {
+				totals[a.Commodity] = new(big.Rat)
+			}
+			totals[a.Commodity].Add(totals[a.Commodity], a.Quantity)
+			if p.Node == target {
+				return formatTotals(totals), true
+			}
+		}
+	}
+	return "", false
+}
+
+func formatTotals(totals map[string]*big.Rat) string {
+	var commodities []string
+	for c := range totals {
+		commodities = append(commodities, c)
+	}
+	sort.Strings(commodities)
+
+	var out []string
+	for _, c := range commodities {
+		out = append(out, strings.TrimSpace(journal.Amount{Commodity: c, Quantity: totals[c]}.String()))
+	}
+	if len(out) == 0 {
+		return "0"
+	}
+	return strings.Join(out, ", ")
+}
+
+// definition locates the `account` directive of the account, or the
+// `commodity` directive of the commodity, under the cursor.
+func (d *document) definition(pos position) *location {
+	offset := d.offset(pos)
+	var account, commodity string
+	d.eachPosting(func(p *parse.PostingNode, _ *parse.XactNode) {
+		if !contains(p.Span(), offset) {
+			return
+		}
+		if contains(d.accountSpan(p), offset) {
+			account = d.journal.Account(p)
+			return
+		}
+		for _, a := range []*parse.AmountNode{p.Amount, p.Price, p.LotPrice, p.BalanceAssertion, p.BalanceAssignment} {
+			if a != nil && a.Commodity != "" && contains(a.Span(), offset) {
+				commodity = a.Commodity
+			}
+		}
+	})
+
+	for _, n := range d.tree.Root.Nodes {
+		switch n := n.(type) {
+		case *parse.AccountNode:
+			if account != "" && n.Account == account {
+				return &location{URI: d.uri, Range: d.firstLineRange(n.Span())}
+			}
+		case *parse.CommodityNode:
+			if commodity != "" && n.Commodity == commodity {
+				return &location{URI: d.uri, Range: d.firstLineRange(n.Span())}
+			}
+		}
+	}
+	return nil
+}
+
+// format returns the edits reformatting the document with the printer
+// of ledgerfmt.  Documents with parse errors are left untouched.
+func (d *document) format() ([]textEdit, error) {
+	if len(d.parseErrors) != 0 {
+		return nil, nil
+	}
+	buf := &bytes.Buffer{}
+	if err := print.New(d.tree).Print(buf); err != nil {
+		return nil, err
+	}
+	if buf.String() == d.text {
+		return []textEdit{}, nil
+	}
+	return []textEdit{{
+		Range:   lspRange{position{}, d.position(parse.Pos(len(d.text)))},
+		NewText: buf.String(),
+	}}, nil
+}
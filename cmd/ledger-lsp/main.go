@@ -0,0 +1,22 @@
+// ledger-lsp is a Language Server Protocol server for Ledger files,
+// speaking over stdin and stdout.
+//
+// It publishes parse and balance errors as diagnostics, completes
+// account names and payees, shows the running balance of an account
+// when hovering a posting, jumps to the `account` and `commodity`
+// directives, and formats documents like ledgerfmt.
+package main
+
+import (
+	"log"
+	"os"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("ledger-lsp: ")
+
+	if err := newServer(os.Stdin, os.Stdout).run(); err != nil {
+		log.Fatalln(err)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+)
+
+// server answers the requests of a single client. Documents are
+// synchronized in full on each change, and analyzed right away.
+type server struct {
+	conn *conn
+	docs map[string]*document
+
+	shutdown bool // set once the client requested a shutdown
+}
+
+func newServer(r io.Reader, w io.Writer) *server {
+	return &server{
+		conn: newConn(r, w),
+		docs: make(map[string]*document),
+	}
+}
+
+// errExit is returned by handle when the client sends `exit`.
+var errExit = fmt.Errorf("exit")
+
+// run serves requests until the client exits or closes the connection.
+func (s *server) run() error {
+	for {
+		msg, err := s.conn.read()
+		if err == io.EOF {
+			return nil
+		}
+		if rerr, ok := err.(*responseError); ok {
+			if err := s.conn.reply(nil, nil, rerr); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		result, err := s.handle(msg)
+		if err == errExit {
+			if !s.shutdown {
+				return fmt.Errorf("exit without shutdown")
+			}
+			return nil
+		}
+		if msg.ID == nil {
+			// Notifications get no answer, errors are only logged.
+			if err != nil {
+				log.Printf("%s: %s", msg.Method, err)
+			}
+			continue
+		}
+
+		var rerr *responseError
+		if err != nil {
+			var ok bool
+			if rerr, ok = err.(*responseError); !ok {
+				rerr = &responseError{codeInternalError, err.Error()}
+			}
+		}
+		if err := s.conn.reply(msg.ID, result, rerr); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *server) handle(msg *message) (interface{}, error) {
+	switch msg.Method {
+	case "initialize":
+		return s.initialize()
+	case "initialized", "$/cancelRequest", "$/setTrace", "textDocument/didSave":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "exit":
+		return nil, errExit
+
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return nil, s.update(params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		if len(params.ContentChanges) == 0 {
+			return nil, nil
+		}
+		// Full synchronization: the last change holds the whole text.
+		text := params.ContentChanges[len(params.ContentChanges)-1].Text
+		return nil, s.update(params.TextDocument.URI, text)
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		delete(s.docs, params.TextDocument.URI)
+		return nil, s.conn.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+			URI:         params.TextDocument.URI,
+			Diagnostics: []diagnostic{},
+		})
+
+	case "textDocument/completion":
+		doc, pos, err := s.positionParams(msg)
+		if err != nil || doc == nil {
+			return nil, err
+		}
+		return doc.completion(pos), nil
+	case "textDocument/hover":
+		doc, pos, err := s.positionParams(msg)
+		if err != nil || doc == nil {
+			return nil, err
+		}
+		return doc.hover(pos), nil
+	case "textDocument/definition":
+		doc, pos, err := s.positionParams(msg)
+		if err != nil || doc == nil {
+			return nil, err
+		}
+		return doc.definition(pos), nil
+	case "textDocument/formatting":
+		var params documentFormattingParams
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		doc := s.docs[params.TextDocument.URI]
+		if doc == nil {
+			return nil, nil
+		}
+		return doc.format()
+	}
+
+	return nil, &responseError{codeMethodNotFound, fmt.Sprintf("method %q not supported", msg.Method)}
+}
+
+func (s *server) initialize() (interface{}, error) {
+	type capabilities struct {
+		TextDocumentSync           int         `json:"textDocumentSync"`
+		CompletionProvider         interface{} `json:"completionProvider"`
+		HoverProvider              bool        `json:"hoverProvider"`
+		DefinitionProvider         bool        `json:"definitionProvider"`
+		DocumentFormattingProvider bool        `json:"documentFormattingProvider"`
+	}
+	return map[string]interface{}{
+		"capabilities": capabilities{
+			TextDocumentSync:           1, // full
+			CompletionProvider:         map[string]interface{}{},
+			HoverProvider:              true,
+			DefinitionProvider:         true,
+			DocumentFormattingProvider: true,
+		},
+		"serverInfo": map[string]string{"name": "ledger-lsp"},
+	}, nil
+}
+
+// update analyzes the new text of a document and publishes its
+// diagnostics.
+func (s *server) update(uri, text string) error {
+	doc := newDocument(uri, text)
+	s.docs[uri] = doc
+	return s.conn.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: doc.diagnostics(),
+	})
+}
+
+// positionParams decodes the parameters of the requests made at a
+// position in a document.  The document is nil if it is not open.
+func (s *server) positionParams(msg *message) (*document, position, error) {
+	var params textDocumentPositionParams
+	if err := unmarshalParams(msg, &params); err != nil {
+		return nil, position{}, err
+	}
+	return s.docs[params.TextDocument.URI], params.Position, nil
+}
+
+func unmarshalParams(msg *message, v interface{}) error {
+	if err := json.Unmarshal(msg.Params, v); err != nil {
+		return &responseError{codeInvalidParams, err.Error()}
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// client is a scripted LSP client, talking to a server over pipes.
+type client struct {
+	t      *testing.T
+	conn   *conn
+	nextID int
+	done   chan error
+}
+
+func newClient(t *testing.T) *client {
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+
+	c := &client{t: t, conn: newConn(clientR, clientW), done: make(chan error, 1)}
+	go func() {
+		c.done <- newServer(serverR, serverW).run()
+		serverW.Close()
+	}()
+	return c
+}
+
+func (c *client) notify(method string, params interface{}) {
+	require.NoError(c.t, c.conn.notify(method, params))
+}
+
+// call sends a request and decodes the result of its response into
+// result.
+func (c *client) call(method string, params, result interface{}) {
+	c.nextID++
+	id := json.RawMessage(strconv.Itoa(c.nextID))
+	raw, err := json.Marshal(params)
+	require.NoError(c.t, err)
+	require.NoError(c.t, c.conn.write(&message{ID: &id, Method: method, Params: raw}))
+
+	msg := c.read()
+	require.NotNil(c.t, msg.ID, "expected a response, got %s", msg.Method)
+	assert.Equal(c.t, string(id), string(*msg.ID))
+	require.Nil(c.t, msg.Error)
+	require.NoError(c.t, json.Unmarshal(msg.Result, result))
+}
+
+func (c *client) read() *message {
+	msg, err := c.conn.read()
+	require.NoError(c.t, err)
+	return msg
+}
+
+func (c *client) diagnostics() publishDiagnosticsParams {
+	msg := c.read()
+	require.Equal(c.t, "textDocument/publishDiagnostics", msg.Method)
+	var params publishDiagnosticsParams
+	require.NoError(c.t, json.Unmarshal(msg.Params, &params))
+	return params
+}
+
+const uri = "file:///home/user/main.ledger"
+
+const text = `account Assets:Checking
+commodity CAD
+
+2016/09/09 Grocery
+  Expenses:Food     20.00 CAD
+  Assets:Checking
+
+2016/09/10 Grocery
+  Expenses:Food     10.00 CAD
+  Assets:Checking  -9.00 CAD
+
+2016/09/11 * * Bad
+  A  1 CAD
+  B
+`
+
+func TestServer(t *testing.T) {
+	c := newClient(t)
+
+	var init struct {
+		Capabilities map[string]interface{} `json:"capabilities"`
+	}
+	c.call("initialize", map[string]interface{}{"capabilities": map[string]interface{}{}}, &init)
+	assert.Equal(t, true, init.Capabilities["hoverProvider"])
+	c.notify("initialized", struct{}{})
+
+	c.notify("textDocument/didOpen", didOpenParams{TextDocument: textDocumentItem{URI: uri, LanguageID: "ledger", Version: 1, Text: text}})
+	diags := c.diagnostics()
+	assert.Equal(t, uri, diags.URI)
+	require.Len(t, diags.Diagnostics, 2)
+	assert.Equal(t, "cannot specify cleared and/or pending more than once", diags.Diagnostics[0].Message)
+	assert.Equal(t, lspRange{position{11, 13}, position{11, 14}}, diags.Diagnostics[0].Range)
+	assert.Equal(t, "transaction does not balance, off by 1.00 CAD", diags.Diagnostics[1].Message)
+	assert.Equal(t, lspRange{position{7, 0}, position{7, 18}}, diags.Diagnostics[1].Range)
+
+	var completion completionList
+	c.call("textDocument/completion", textDocumentPositionParams{textDocumentIdentifier{uri}, position{4, 4}}, &completion)
+	var labels []string
+	for _, item := range completion.Items {
+		labels = append(labels, item.Label)
+	}
+	assert.Equal(t, []string{"Assets:Checking", "Expenses:Food"}, labels)
+
+	c.call("textDocument/completion", textDocumentPositionParams{textDocumentIdentifier{uri}, position{3, 12}}, &completion)
+	require.Len(t, completion.Items, 1)
+	assert.Equal(t, "Grocery", completion.Items[0].Label)
+
+	var h hover
+	c.call("textDocument/hover", textDocumentPositionParams{textDocumentIdentifier{uri}, position{9, 4}}, &h)
+	assert.Equal(t, "**Assets:Checking**\n\nRunning balance: -29 CAD", h.Contents.Value)
+
+	var loc location
+	c.call("textDocument/definition", textDocumentPositionParams{textDocumentIdentifier{uri}, position{5, 5}}, &loc)
+	assert.Equal(t, location{uri, lspRange{position{0, 0}, position{0, 23}}}, loc)
+	c.call("textDocument/definition", textDocumentPositionParams{textDocumentIdentifier{uri}, position{4, 26}}, &loc)
+	assert.Equal(t, location{uri, lspRange{position{1, 0}, position{1, 13}}}, loc)
+
+	// Fix the parse error, so the document can be formatted.
+	fixed := text[:len(text)-len("2016/09/11 * * Bad\n  A  1 CAD\n  B\n")]
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": uri, "version": 2},
+		"contentChanges": []map[string]string{{"text": fixed}},
+	})
+	assert.Len(t, c.diagnostics().Diagnostics, 1)
+
+	var edits []textEdit
+	c.call("textDocument/formatting", documentFormattingParams{textDocumentIdentifier{uri}}, &edits)
+	require.Len(t, edits, 1)
+	assert.Contains(t, edits[0].NewText, "2016-09-10 Grocery\n")
+
+	var null interface{}
+	c.call("shutdown", nil, &null)
+	assert.Nil(t, null)
+	c.notify("exit", nil)
+	assert.NoError(t, <-c.done)
+}
+
+func TestServerInclude(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "inc.ledger"), []byte(`2016/09/10 Grocery
+  Expenses:Food     10.00 CAD
+  Assets:Checking  -9.00 CAD
+`), 0644))
+	mainURI := "file://" + filepath.Join(dir, "main.ledger")
+
+	c := newClient(t)
+	c.notify("textDocument/didOpen", didOpenParams{TextDocument: textDocumentItem{URI: mainURI, LanguageID: "ledger", Version: 1, Text: "include inc.ledger\n"}})
+	diags := c.diagnostics()
+	assert.Equal(t, mainURI, diags.URI)
+	assert.Empty(t, diags.Diagnostics)
+
+	var null interface{}
+	c.call("shutdown", nil, &null)
+	c.notify("exit", nil)
+	assert.NoError(t, <-c.done)
+}
+
+func TestServerApplyAccount(t *testing.T) {
+	c := newClient(t)
+	c.notify("textDocument/didOpen", didOpenParams{TextDocument: textDocumentItem{URI: uri, LanguageID: "ledger", Version: 1, Text: `account Personal:Assets:Cash
+
+apply account Personal
+alias Cash=Assets:Cash
+2016/09/09 Grocery
+  Expenses:Food     20.00 CAD
+  Assets:Cash
+end apply account
+
+2016/09/10 Grocery
+  Expenses:Food     10.00 CAD
+  Cash
+`}})
+	assert.Empty(t, c.diagnostics().Diagnostics)
+
+	var h hover
+	c.call("textDocument/hover", textDocumentPositionParams{textDocumentIdentifier{uri}, position{6, 4}}, &h)
+	assert.Equal(t, "**Personal:Assets:Cash**\n\nRunning balance: -20 CAD", h.Contents.Value)
+	c.call("textDocument/hover", textDocumentPositionParams{textDocumentIdentifier{uri}, position{11, 3}}, &h)
+	assert.Equal(t, "**Personal:Assets:Cash**\n\nRunning balance: -30 CAD", h.Contents.Value)
+
+	var loc location
+	c.call("textDocument/definition", textDocumentPositionParams{textDocumentIdentifier{uri}, position{11, 3}}, &loc)
+	assert.Equal(t, location{uri, lspRange{position{0, 0}, position{0, 28}}}, loc)
+
+	var completion completionList
+	c.call("textDocument/completion", textDocumentPositionParams{textDocumentIdentifier{uri}, position{10, 2}}, &completion)
+	var labels []string
+	for _, item := range completion.Items {
+		labels = append(labels, item.Label)
+	}
+	assert.Equal(t, []string{"Expenses:Food", "Personal:Assets:Cash", "Personal:Expenses:Food"}, labels)
+
+	var null interface{}
+	c.call("shutdown", nil, &null)
+	c.notify("exit", nil)
+	assert.NoError(t, <-c.done)
+}
+
+func TestServerUnknownMethod(t *testing.T) {
+	c := newClient(t)
+
+	id := json.RawMessage(`1`)
+	require.NoError(t, c.conn.write(&message{ID: &id, Method: "workspace/symbol", Params: json.RawMessage(`{}`)}))
+	msg := c.read()
+	require.NotNil(t, msg.Error)
+	assert.Equal(t, codeMethodNotFound, msg.Error.Code)
+}
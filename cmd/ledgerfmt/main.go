@@ -42,9 +42,13 @@ func main() {
 	}
 
 	t := parse.New(filename, string(cnt))
+	t.Mode = parse.AllErrors
 	err = t.Parse()
-	if err != nil {
-		log.Fatalln("Parsing error:", err)
+	if errs, ok := err.(parse.ErrorList); ok {
+		for _, err := range errs {
+			log.Printf("Parsing error: %s:%d:%d: %s\n%s", err.File, err.Line, err.Column, err.Msg, err.Snippet())
+		}
+		os.Exit(1)
 	}
 
 	if *sortXacts {
@@ -27,6 +27,9 @@ func (a *Account) Add(am *Amount) {
 		}
 		a.Amounts[am.Commodity] = accAmount
 	}
+	if accAmount.Style == nil {
+		accAmount.Style = am.Style
+	}
 	accAmount.Quantity.Add(accAmount.Quantity, am.Quantity)
 }
 
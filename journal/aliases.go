@@ -0,0 +1,104 @@
+package journal
+
+import (
+	"strings"
+
+	"github.com/abourget/ledger/lpath"
+	"github.com/abourget/ledger/parse"
+)
+
+// accountNames returns the full account names of the postings, as
+// resolved by resolveAccounts, computing them on first use.
+func (j *Journal) accountNames() map[*parse.PostingNode]string {
+	if j.resolvedAccounts == nil {
+		j.resolveAccounts()
+	}
+	return j.resolvedAccounts
+}
+
+// resolveAccounts walks through the journal in order, and resolves the
+// account of each posting with the `alias` and `apply account`
+// directives preceding it, and the `alias` sub-directives of the
+// `account` directives.  Like in Ledger, an alias matches the whole
+// account name or its first part, like "Checking:Sub", and is not
+// prefixed by the `apply account` blocks it appears in, while its
+// target is.
+func (j *Journal) resolveAccounts() {
+	names := make(map[*parse.PostingNode]string)
+	j.resolvedAccounts = names
+
+	aliases := make(map[string]string)
+	var applied []string // account of each open `apply` block, empty for the other directives
+	prefixed := func(name string) string {
+		for i := len(applied) - 1; i >= 0; i-- {
+			if applied[i] != "" {
+				name = applied[i] + lpath.Separator + name
+			}
+		}
+		return name
+	}
+	resolve := func(name string) string {
+		if full, ok := aliases[name]; ok {
+			return full
+		}
+		if i := strings.Index(name, lpath.Separator); i > 0 {
+			if full, ok := aliases[name[:i]]; ok {
+				return full + name[i:]
+			}
+		}
+		return prefixed(name)
+	}
+	addPostings := func(postings []*parse.PostingNode) {
+		for _, p := range postings {
+			names[p] = resolve(strings.Trim(p.Account, "()[]"))
+		}
+	}
+
+	// Errors are reported when listing the transactions.
+	_ = j.walk(func(n parse.Node) error {
+		switch node := n.(type) {
+		case *parse.AliasNode:
+			aliases[node.Alias] = prefixed(node.Account)
+		case *parse.AccountNode:
+			for _, alias := range node.Aliases {
+				aliases[alias] = prefixed(node.Account)
+			}
+		case *parse.ApplyNode:
+			var account string
+			if node.Directive == "account" {
+				account = node.Args
+			}
+			applied = append(applied, account)
+		case *parse.EndApplyNode:
+			if len(applied) != 0 {
+				applied = applied[:len(applied)-1]
+			}
+		case *parse.XactNode:
+			addPostings(node.Postings)
+		case *parse.AutoXactNode:
+			addPostings(node.Postings)
+		case *parse.PeriodicXactNode:
+			addPostings(node.Postings)
+		}
+		return nil
+	})
+}
+
+// Account returns the full account name of the posting n, written in
+// the journal or the journals it includes, after the `alias` and `apply
+// account` directives, see resolveAccounts.
+func (j *Journal) Account(n *parse.PostingNode) string {
+	if name, ok := j.accountNames()[n]; ok {
+		return name
+	}
+	return strings.Trim(n.Account, "()[]")
+}
+
+// account returns the full account name of the posting n, see
+// Journal.Account.
+func (tx *Transaction) account(n *parse.PostingNode) string {
+	if tx.journal != nil {
+		return tx.journal.Account(n)
+	}
+	return strings.Trim(n.Account, "()[]")
+}
@@ -0,0 +1,176 @@
+package journal
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/abourget/ledger/parse"
+)
+
+// runningBalances is the result of walking through the transactions
+// of a journal in date order: the amounts implied by balance
+// assignments, and the failed balance assertions.
+type runningBalances struct {
+	assigned map[*parse.PostingNode]*Amount
+	errs     ErrorList
+	err      error // error listing the transactions
+}
+
+// balances returns the running balances of the journal, computing them
+// on first use.
+func (j *Journal) balances() *runningBalances {
+	if j.runningBalances == nil {
+		j.computeBalances()
+	}
+	return j.runningBalances
+}
+
+// resetBalances drops the running balances, after the journal changes.
+func (j *Journal) resetBalances() {
+	j.runningBalances = nil
+}
+
+// CheckBalances walks through the transactions in date order, keeping
+// a running total per account, and reports the balance assertions
+// (`= AMOUNT` after a posting's amount) not matching the total of the
+// account.
+func (j *Journal) CheckBalances() (ErrorList, error) {
+	b := j.balances()
+	return b.errs, b.err
+}
+
+func (j *Journal) computeBalances() {
+	// The state is registered before it is filled, so that the postings
+	// without amount can be resolved with the assignments already
+	// computed, see Transaction.knownAmount.
+	b := &runningBalances{assigned: make(map[*parse.PostingNode]*Amount)}
+	j.runningBalances = b
+
+	txs, err := j.Transactions()
+	if err != nil {
+		b.err = err
+		return
+	}
+	sort.SliceStable(txs, func(i, k int) bool {
+		return txs[i].Node.Date.Before(txs[k].Node.Date)
+	})
+
+	totals := make(map[string]map[string]*big.Rat)
+	total := func(account, commodity string) *big.Rat {
+		acc, ok := totals[account]
+		if !ok {
+			acc = make(map[string]*big.Rat)
+			totals[account] = acc
+		}
+		q, ok := acc[commodity]
+		if !ok {
+			q = new(big.Rat)
+			acc[commodity] = q
+		}
+		return q
+	}
+
+	for _, tx := range txs {
+		postings := tx.Postings()
+
+		// Assignments first, so the postings without amount can
+		// balance them.
+		pending := make(map[string]map[string]*big.Rat)
+		for _, p := range postings {
+			n := p.Node
+			var a *Amount
+			switch {
+			case n.Amount != nil:
+				a, err = tx.knownAmount(n)
+				if err != nil {
+					continue
+				}
+			case n.BalanceAssignment != nil:
+				target, err := j.amountFromNode(n.BalanceAssignment, nil)
+				if err != nil {
+					b.errs = append(b.errs, nodeError(j.tree, n, "%s", err))
+					continue
+				}
+				current := new(big.Rat).Set(total(p.Account(), target.Commodity))
+				if acc, ok := pending[p.Account()]; ok && acc[target.Commodity] != nil {
+					current.Add(current, acc[target.Commodity])
+				}
+				a = &Amount{Commodity: target.Commodity, Quantity: current.Sub(target.Quantity, current)}
+				b.assigned[n] = a
+			default:
+				continue
+			}
+
+			acc, ok := pending[p.Account()]
+			if !ok {
+				acc = make(map[string]*big.Rat)
+				pending[p.Account()] = acc
+			}
+			if acc[a.Commodity] == nil {
+				acc[a.Commodity] = new(big.Rat)
+			}
+			acc[a.Commodity].Add(acc[a.Commodity], a.Quantity)
+		}
+
+		for _, p := range postings {
+			a, err := tx.knownAmount(p.Node)
+			if err != nil {
+				// Reported by validateXact.
+				continue
+			}
+			if a == nil {
+				a = p.Amount()
+			}
+			if a == nil {
+				continue
+			}
+			q := total(p.Account(), a.Commodity)
+			q.Add(q, a.Quantity)
+
+			if n := p.Node; n.BalanceAssertion != nil {
+				if err := j.checkAssertion(totals[p.Account()], n.BalanceAssertion); err != nil {
+					b.errs = append(b.errs, nodeError(j.tree, n, "balance assertion failed for account '%s': %s", p.Account(), err))
+				}
+			}
+		}
+	}
+}
+
+// checkAssertion compares the total of an account, per commodity, to
+// the expected amount, at the precision written in the assertion.  An
+// assertion of zero without commodity expects all commodities to be
+// zero.
+func (j *Journal) checkAssertion(totals map[string]*big.Rat, expectedNode *parse.AmountNode) error {
+	expected, err := j.amountFromNode(expectedNode, nil)
+	if err != nil {
+		return err
+	}
+
+	if expected.Commodity == "" && expected.Quantity.Sign() == 0 {
+		var got []string
+		for commodity, q := range totals {
+			if q.Sign() != 0 {
+				got = append(got, Amount{Commodity: commodity, Quantity: q, Style: j.Styles().Style(commodity)}.String())
+			}
+		}
+		if len(got) == 0 {
+			return nil
+		}
+		sort.Strings(got)
+		return fmt.Errorf("expected 0, got %s", strings.Join(got, ", "))
+	}
+
+	got := new(big.Rat)
+	if q := totals[expected.Commodity]; q != nil {
+		got.Set(q)
+	}
+	diff := new(big.Rat).Sub(got, expected.Quantity)
+	if isRoundedZero(diff, j.decimals(expectedNode)) {
+		return nil
+	}
+	style := j.Styles().Style(expected.Commodity)
+	expected.Style = style
+	return fmt.Errorf("expected %s, got %s (difference: %s)", expected, Amount{Commodity: expected.Commodity, Quantity: got, Style: style}, Amount{Commodity: expected.Commodity, Quantity: diff, Style: style})
+}
@@ -0,0 +1,46 @@
+package journal
+
+import (
+	"fmt"
+
+	"github.com/abourget/ledger/parse"
+)
+
+// Error is a problem found in a journal, located at the node causing
+// it.
+type Error struct {
+	Location string     // "file:line:column" of the faulty node
+	Node     parse.Node // the faulty node, whose Span locates the problem precisely
+	Msg      string
+}
+
+func (e *Error) Error() string {
+	return e.Location + ": " + e.Msg
+}
+
+// nodeError builds an Error located at node n of tree t.
+func nodeError(t *parse.Tree, n parse.Node, format string, args ...interface{}) *Error {
+	return &Error{Location: t.Location(n), Node: n, Msg: fmt.Sprintf(format, args...)}
+}
+
+// ErrorList is a list of errors found in a journal. It is itself an
+// error.
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+// Err returns nil when the list is empty, or the list itself otherwise.
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
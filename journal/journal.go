@@ -15,14 +15,38 @@ type Journal struct {
 	tree *parse.Tree
 
 	IncludedJournals map[string]*Journal
+	Warnings         ErrorList // Problems reported by the OpenOptions.Strict checks.
+
+	decimalComma     bool                          // quantities are written like "1.234,56", see OpenOptions.DecimalComma
+	runningBalances  *runningBalances              // cache of the balance assignments, see balances()
+	resolvedAccounts map[*parse.PostingNode]string // cache of the full account names, see accountNames()
+	styles           *Styles                       // cache of the styles of the commodities, see Styles()
+	resolvedPayees   *payees                       // cache of the payee resolver, see payees()
+	prices           PriceSource                   // prices of the `market` function, see SetPrices()
 }
 
-func Open(path string) (*Journal, error) {
+// Open parses the journal at `path`, and checks it further as told by
+// opts, see OpenOptions.
+func Open(path string, opts OpenOptions) (*Journal, error) {
 	t, err := parse.Parse(path)
 	if err != nil {
 		return nil, err
 	}
-	return NewFromTree(t), nil
+	j := NewFromTree(t)
+	j.decimalComma = opts.DecimalComma
+
+	if opts.Strict || opts.Pedantic {
+		errs, err := j.CheckDeclarations()
+		if err != nil {
+			return nil, err
+		}
+		if opts.Pedantic && len(errs) != 0 {
+			return nil, errs
+		}
+		j.Warnings = errs
+	}
+
+	return j, nil
 }
 
 func NewFromTree(tree *parse.Tree) *Journal {
@@ -33,26 +57,63 @@ func NewFromTree(tree *parse.Tree) *Journal {
 	return j
 }
 
-func (j *Journal) Transactions() ([]*Transaction, error) {
-	txs := make([]*Transaction, 0)
+// walk calls fn for each top-level node of the journal, in order,
+// descending into included journals where they are included.
+func (j *Journal) walk(fn func(n parse.Node) error) error {
 	for _, n := range j.tree.Root.Nodes {
-		if x, ok := n.(*parse.XactNode); ok {
-			txs = append(txs, &Transaction{x})
-		}
-
 		if d, ok := n.(*parse.DirectiveNode); ok && d.Directive == "include" {
 			inc, err := j.IncludeJournal(d.Args)
 			if err != nil {
-				return txs, err
+				return err
 			}
-			incTxs, err := inc.Transactions()
-			if err != nil {
-				return txs, err
+			if err := inc.walk(fn); err != nil {
+				return err
 			}
-			txs = append(txs, incTxs...)
+			continue
+		}
+
+		if err := fn(n); err != nil {
+			return err
 		}
 	}
-	return txs, nil
+	return nil
+}
+
+func (j *Journal) Transactions() ([]*Transaction, error) {
+	txs := make([]*Transaction, 0)
+	err := j.walk(func(n parse.Node) error {
+		if x, ok := n.(*parse.XactNode); ok {
+			txs = append(txs, &Transaction{Node: x, journal: j})
+		}
+		return nil
+	})
+	return txs, err
+}
+
+// PeriodicTransactions returns the periodic transactions (starting
+// with `~`) of the journal and its included journals.
+func (j *Journal) PeriodicTransactions() ([]*PeriodicTransaction, error) {
+	pts := make([]*PeriodicTransaction, 0)
+	err := j.walk(func(n parse.Node) error {
+		if x, ok := n.(*parse.PeriodicXactNode); ok {
+			pts = append(pts, &PeriodicTransaction{Node: x, journal: j})
+		}
+		return nil
+	})
+	return pts, err
+}
+
+// DeclaredAccounts returns the accounts declared with the `account`
+// directive in the journal and its included journals.
+func (j *Journal) DeclaredAccounts() ([]*parse.AccountNode, error) {
+	accounts := make([]*parse.AccountNode, 0)
+	err := j.walk(func(n parse.Node) error {
+		if a, ok := n.(*parse.AccountNode); ok {
+			accounts = append(accounts, a)
+		}
+		return nil
+	})
+	return accounts, err
 }
 
 func (j *Journal) IncludeJournal(path string) (*Journal, error) {
@@ -61,7 +122,7 @@ func (j *Journal) IncludeJournal(path string) (*Journal, error) {
 		return inc, nil
 	}
 
-	inc, err := Open(path)
+	inc, err := Open(path, OpenOptions{DecimalComma: j.decimalComma})
 	if err != nil {
 		return nil, err
 	}
@@ -78,7 +139,10 @@ func (j *Journal) AddTransaction(date time.Time, desc string) *Transaction {
 	n.Description = desc
 
 	j.tree.Root.Nodes = append(j.tree.Root.Nodes, sn, n)
-	return &Transaction{n}
+	j.resetBalances()
+	j.resolvedAccounts = nil
+	j.styles = nil
+	return &Transaction{Node: n, journal: j}
 }
 
 func (j *Journal) Marshal() ([]byte, error) {
@@ -0,0 +1,641 @@
+package journal
+
+import (
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/abourget/ledger/parse"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newJournal(t *testing.T, input string) *Journal {
+	tree := parse.New("file.ledger", input)
+	require.NoError(t, tree.Parse())
+	return NewFromTree(tree)
+}
+
+func errorStrings(errs ErrorList) []string {
+	var out []string
+	for _, err := range errs {
+		out = append(out, err.Error())
+	}
+	return out
+}
+
+func TestCheckDeclarations(t *testing.T) {
+	j := newJournal(t, `account Expenses:Groceries  ; food only
+account Assets:Checking
+  ; main account
+  alias Checking
+commodity CAD
+
+2016/09/09 Grocery
+  Expenses:Grocieres     20.00 CAD
+  Checking              -20.00 CAD
+
+2016/09/10 Exchange
+  Expenses:Groceries     20.00 USD @ 1.30 CAD
+  [Assets:Checking]
+  Assets:Savings
+`)
+	errs, err := j.CheckDeclarations()
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"file.ledger:8:3: unknown account 'Expenses:Grocieres'",
+		"file.ledger:12:3: unknown commodity 'USD'",
+		"file.ledger:14:3: unknown account 'Assets:Savings'",
+	}, errorStrings(errs))
+}
+
+func TestValidate(t *testing.T) {
+	j := newJournal(t, `2016/09/09 Balanced
+  Expenses:Food     20.00 CAD
+  Assets:Cash
+
+2016/09/09 Unbalanced
+  Expenses:Food     20.00 CAD
+  Assets:Cash      -19.00 CAD
+
+2016/09/10 Exchange
+  Assets:USD        10.00 USD @ 1.333 CAD
+  Assets:CAD       -13.33 CAD
+
+2016/09/10 Exchange whole
+  Assets:USD       -10.00 USD @@ 13.00 CAD
+  Assets:CAD        13.00 CAD
+
+2016/09/11 Lot
+  Assets:Broker     10 AAPL {50.00 USD}
+  Assets:USD       -500.00 USD
+
+2016/09/11 Virtual
+  Expenses:Food     20.00 CAD
+  Assets:Cash      -20.00 CAD
+  (Budget:Food)    -20.00 CAD
+  [Savings:Food]    20.00 CAD
+  [Savings:Spent]  -15.00 CAD
+
+2016/09/12 Two nulls
+  Expenses:Food     20.00 CAD
+  Assets:Cash
+  Assets:Bank
+
+2016/09/12 Multi
+  Expenses:Food     20.00 CAD
+  Assets:Cash      -20.00 USD
+
+2016/09/13 Null per group
+  Expenses:Food     10.00 CAD
+  Assets:Bank
+  [Savings:Food]     5.00 CAD
+  [Assets:Other]
+`)
+	errs, err := j.Validate()
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"file.ledger:5:1: transaction does not balance, off by 1.000 CAD",
+		"file.ledger:21:1: transaction does not balance, off by 5.000 CAD",
+		"file.ledger:28:1: only one posting with null amount allowed per transaction",
+		"file.ledger:33:1: transaction does not balance, off by 20.000 CAD, -20.00 USD",
+	}, errorStrings(errs))
+}
+
+func TestImplicitAmount(t *testing.T) {
+	j := newJournal(t, `2016/09/10 Exchange
+  Assets:USD        10.00 USD @ 1.30 CAD
+  Assets:CAD
+  [Savings]         5 CAD
+  [Assets:CAD]
+`)
+	txs, err := j.Transactions()
+	require.NoError(t, err)
+	require.Len(t, txs, 1)
+	assert.Equal(t, "-13.00 CAD", txs[0].Postings()[1].Amount().String())
+	assert.Equal(t, "-5.00 CAD", txs[0].Postings()[3].Amount().String())
+}
+
+func TestValueExpressions(t *testing.T) {
+	j := newJournal(t, `2016/09/10 Split
+  Expenses:Food     (123 + 2 * 3 USD)
+  Expenses:Rent     (date < [2016/10/01] ? 100 USD : 200 USD)
+  Assets:Bank
+
+2016/09/11 Broken
+  Expenses:Food     (10 USD + 2 CAD)
+  Assets:Bank
+
+2016/09/12 Amount of a plain transaction
+  Expenses:Food     40 USD
+  Expenses:Tips     (amount * 0.15)
+  Assets:Bank
+
+2016/09/13 Unknown variable
+  Expenses:Food     (total)
+  Assets:Bank
+`)
+	txs, err := j.Transactions()
+	require.NoError(t, err)
+	require.Len(t, txs, 4)
+	assert.Equal(t, "129 USD", txs[0].Postings()[0].Amount().String())
+	assert.Equal(t, "100 USD", txs[0].Postings()[1].Amount().String())
+	assert.Equal(t, "-229 USD", txs[0].Postings()[2].Amount().String())
+	assert.Nil(t, txs[1].Postings()[0].Amount())
+	assert.Nil(t, txs[2].Postings()[1].Amount())
+	assert.Nil(t, txs[3].Postings()[0].Amount())
+
+	errs, err := j.Validate()
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		`file.ledger:6:1: value expression "(10 USD + 2 CAD)", at 8: mismatched commodities "USD" and "CAD"`,
+		`file.ledger:10:1: value expression "(amount * 0.15)", at 1: unknown variable "amount"`,
+		`file.ledger:15:1: value expression "(total)", at 1: unknown variable "total"`,
+	}, errorStrings(errs))
+}
+
+// fixedPrices prices every commodity at the same rate in any other.
+type fixedPrices struct{ rate *big.Rat }
+
+func (p fixedPrices) Price(commodity, target string, at time.Time) (*big.Rat, bool) {
+	return p.rate, p.rate != nil
+}
+
+func TestMarket(t *testing.T) {
+	j := newJournal(t, `2016/09/10 Trip
+  Expenses:Travel   (market(10 USD, date, "CAD"))
+  Assets:Bank
+`)
+	errs, err := j.Validate()
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		`file.ledger:1:1: value expression "(market(10 USD, date, \"CAD\"))", at 1: no prices to value 10 USD`,
+	}, errorStrings(errs))
+
+	j.SetPrices(fixedPrices{big.NewRat(13, 10)})
+	txs, err := j.Transactions()
+	require.NoError(t, err)
+	assert.Equal(t, "13 CAD", txs[0].Postings()[0].Amount().String())
+	assert.Equal(t, "-13 CAD", txs[0].Postings()[1].Amount().String())
+
+	j.SetPrices(fixedPrices{})
+	errs, err = j.Validate()
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		`file.ledger:1:1: value expression "(market(10 USD, date, \"CAD\"))", at 1: no price of USD in CAD on 2016/09/10`,
+	}, errorStrings(errs))
+}
+
+func TestCheckBalances(t *testing.T) {
+	j := newJournal(t, `2016/09/10 Paycheck
+  Assets:Bank       1000.00 USD = 1000.00 USD
+  Income:Salary
+
+2016/09/01 Opening, sorted first
+  Assets:Bank        200.00 USD
+  Assets:Cash         50.00 CAD
+  Equity:Opening
+
+2016/09/11 Reconcile
+  Assets:Bank               = 1150.00 USD
+  Expenses:Fees
+
+2016/09/12 Wrong
+  Assets:Bank         -50.00 USD = 1000.00 USD
+  Assets:Cash         50.00 USD = 0
+  Expenses:Food
+`)
+	errs, err := j.CheckBalances()
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"file.ledger:2:3: balance assertion failed for account 'Assets:Bank': expected 1000.00 USD, got 1200.00 USD (difference: 200.00 USD)",
+		"file.ledger:15:3: balance assertion failed for account 'Assets:Bank': expected 1000.00 USD, got 1100.00 USD (difference: 100.00 USD)",
+		"file.ledger:16:3: balance assertion failed for account 'Assets:Cash': expected 0, got 50.00 CAD, 50.00 USD",
+	}, errorStrings(errs))
+
+	txs, err := j.Transactions()
+	require.NoError(t, err)
+	reconcile := txs[2].Postings()
+	assert.Equal(t, "-50.00 USD", reconcile[0].Amount().String())
+	assert.Equal(t, "50.00 USD", reconcile[1].Amount().String())
+}
+
+func TestTags(t *testing.T) {
+	j := newJournal(t, `2016/09/09 Grocery  ; :groceries:receipt:
+  ; Project: Kitchen
+  ; Receipt:: "scans/2016-09-09.pdf"
+  Expenses:Food     20.00 CAD  ; Shared:: 12.50
+  ; :food:
+  Assets:Cash       ; Project: Household
+  ; Due:: [2016/10/01]
+`)
+	txs, err := j.Transactions()
+	require.NoError(t, err)
+
+	tags := txs[0].Tags()
+	assert.Len(t, tags, 4)
+	assert.Equal(t, TagValue{}, tags["groceries"])
+	assert.Equal(t, TagValue{}, tags["receipt"])
+	assert.Equal(t, TagValue{Raw: "Kitchen"}, tags["Project"])
+	assert.Equal(t, TagValue{Raw: `"scans/2016-09-09.pdf"`, Typed: true, Value: "scans/2016-09-09.pdf"}, tags["Receipt"])
+
+	postings := txs[0].Postings()
+	tags = postings[0].Tags()
+	assert.Len(t, tags, 6)
+	assert.Equal(t, "Kitchen", tags["Project"].Raw)
+	assert.Equal(t, big.NewRat(25, 2), tags["Shared"].Value)
+	assert.Contains(t, tags, "food")
+
+	tags = postings[1].Tags()
+	assert.Equal(t, "Household", tags["Project"].Raw)
+	assert.Equal(t, time.Date(2016, time.October, 1, 0, 0, 0, 0, time.UTC), tags["Due"].Value)
+	assert.NotContains(t, tags, "food")
+}
+
+func TestLots(t *testing.T) {
+	input := `2017/01/10 Buy
+  Assets:Broker    10 AAPL @ 100 USD
+  Assets:Cash
+
+2017/02/10 Buy
+  Assets:Broker    10 AAPL @@ 1200 USD
+  Assets:Cash
+
+2017/03/10 Sell
+  Assets:Broker    -15 AAPL @ 130 USD
+  Assets:Cash
+
+2017/03/11 Transfer
+  Assets:Cash      -100 USD
+  Assets:Bank
+`
+	gains := func(sales []*Sale) []string {
+		var out []string
+		for _, s := range sales {
+			out = append(out, s.Quantity.RatString()+" "+s.Lot.Date.Format("01/02")+" "+s.Cost.String()+" "+s.Proceeds.String()+" "+s.Gain.String())
+		}
+		return out
+	}
+
+	lots, err := newJournal(t, input).Lots(FIFO)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"10 01/10 1000 USD 1300 USD 300 USD",
+		"5 02/10 600 USD 650 USD 50 USD",
+	}, gains(lots.Sales))
+	require.Len(t, lots.Open, 1)
+	assert.Equal(t, "5", lots.Open[0].Quantity.RatString())
+	assert.Equal(t, "120 USD", lots.Open[0].Cost.String())
+
+	holdings := lots.Unrealized(time.Now(), func(commodity, target string, at time.Time) (*big.Rat, bool) {
+		return big.NewRat(150, 1), commodity == "AAPL" && target == "USD"
+	})
+	require.Len(t, holdings, 1)
+	assert.Equal(t, "600 USD", holdings[0].Cost.String())
+	assert.Equal(t, "750 USD", holdings[0].Value.String())
+	assert.Equal(t, "150 USD", holdings[0].Gain.String())
+
+	lots, err = newJournal(t, input).Lots(LIFO)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"10 02/10 1200 USD 1300 USD 100 USD",
+		"5 01/10 500 USD 650 USD 150 USD",
+	}, gains(lots.Sales))
+
+	lots, err = newJournal(t, input+`
+2017/04/01 Sell
+  Assets:Broker    -2 AAPL {100 USD} [2017/01/10] @ 90 USD
+  Assets:Cash
+`).Lots(LIFO)
+	require.NoError(t, err)
+	require.Len(t, lots.Sales, 3)
+	assert.Equal(t, "-20 USD", lots.Sales[2].Gain.String())
+	assert.Equal(t, "3", lots.Open[0].Quantity.RatString())
+
+	_, err = newJournal(t, input+`
+2017/04/01 Sell
+  Assets:Broker    -1 AAPL {999 USD} @ 90 USD
+  Assets:Cash
+`).Lots(FIFO)
+	assert.EqualError(t, err, "file.ledger:18:3: no lot of AAPL matching {999 USD} in Assets:Broker")
+
+	_, err = newJournal(t, input+`
+2017/04/01 Sell
+  Assets:Broker    -6 AAPL {120 USD} @ 90 USD
+  Assets:Cash
+`).Lots(FIFO)
+	assert.EqualError(t, err, "file.ledger:18:3: cannot reduce 6 AAPL in Assets:Broker: only 5 AAPL held in lots")
+
+	// The quantity beyond the lots is held without lots.
+	lots, err = newJournal(t, input+`
+2017/04/01 Sell
+  Assets:Broker    -6 AAPL @ 90 USD
+  Assets:Cash
+`).Lots(FIFO)
+	require.NoError(t, err)
+	assert.Equal(t, "5 02/10 600 USD 450 USD -150 USD", gains(lots.Sales)[2])
+	assert.Empty(t, lots.Open)
+
+	// Spending closes the lots, without moving them to the expenses.
+	lots, err = newJournal(t, `2017/01/01 Exchange
+  Assets:EUR       100 EUR @ 1.10 USD
+  Assets:USD
+
+2017/01/02 Salary
+  Assets:EUR       400 EUR
+  Income:Salary
+
+2017/01/03 Rent
+  Expenses:Rent    500 EUR
+  Assets:EUR
+`).Lots(FIFO)
+	require.NoError(t, err)
+	assert.Empty(t, lots.Sales)
+	assert.Empty(t, lots.Open)
+
+	lots, err = newJournal(t, input+`
+2017/04/01 Transfer
+  Assets:Broker    -5 AAPL
+  Assets:IRA        3 AAPL
+  Assets:Other      2 AAPL
+
+2017/05/01 Sell
+  Assets:IRA       -3 AAPL @ 150 USD
+  Assets:Cash
+`).Lots(FIFO)
+	require.NoError(t, err)
+	assert.Equal(t, "3 02/10 360 USD 450 USD 90 USD", gains(lots.Sales)[2])
+	require.Len(t, lots.Open, 1)
+	assert.Equal(t, "Assets:Other", lots.Open[0].Account)
+	assert.Equal(t, "2", lots.Open[0].Quantity.RatString())
+	assert.Equal(t, "120 USD", lots.Open[0].Cost.String())
+}
+
+func TestAccountResolution(t *testing.T) {
+	j := newJournal(t, `alias Checking=Assets:Bank:Checking
+account Assets:Bank:Checking
+  alias Bank
+account Personal:Expenses:Food
+account Personal:Assets:Cash
+account Assets:Bank:Checking:Sub
+commodity CAD
+
+
+apply account Personal  ; personal expenses
+alias Cash=Assets:Cash  ; petty cash
+2016/09/09 Grocery
+  Expenses:Food     20.00 CAD
+  Checking
+  (Cash)            1 CAD
+end apply account
+
+2016/09/10 Transfer
+  Checking:Sub      5 CAD
+  Cash
+
+2016/09/11 Fee
+  Bank              1 CAD
+  Cash
+`)
+	txs, err := j.Transactions()
+	require.NoError(t, err)
+	require.Len(t, txs, 3)
+
+	var accounts []string
+	for _, tx := range txs {
+		for _, p := range tx.Postings() {
+			accounts = append(accounts, p.Account())
+		}
+	}
+	assert.Equal(t, []string{
+		"Personal:Expenses:Food",
+		"Assets:Bank:Checking",
+		"Personal:Assets:Cash",
+		"Assets:Bank:Checking:Sub",
+		"Personal:Assets:Cash",
+		"Assets:Bank:Checking",
+		"Personal:Assets:Cash",
+	}, accounts)
+	assert.NotNil(t, txs[0].Posting("Assets:Bank:Checking"))
+	assert.NotNil(t, txs[0].Posting("Checking"))
+
+	errs, err := j.CheckDeclarations()
+	require.NoError(t, err)
+	assert.Empty(t, errs)
+}
+
+func TestPayees(t *testing.T) {
+	j := newJournal(t, `payee Amazon  ; online
+  alias ^AMZN Mktp
+  ; the website
+  alias amazon\.com
+
+payee Hydro-Quebec
+  alias hydro
+  uuid 2a2e21d4
+
+2016/09/09 AMZN Mktp US*2K4
+  Expenses:Books     10 CAD
+  Assets:Bank
+
+2016/09/10 www.Amazon.com
+  Expenses:Books     10 CAD
+  Assets:Bank
+
+2016/09/11 HQ bill
+  ; UUID: 2a2e21d4
+  Expenses:Utilities 50 CAD
+  Assets:Bank
+
+2016/09/12 AMZN Mktp CA
+  ; Payee: Library
+  Expenses:Books     10 CAD
+  Assets:Bank
+
+2016/09/13 Grocery
+  Expenses:Food      20 CAD
+  Expenses:Food      15 CAD  ; Payee: Bakery
+  Assets:Bank
+`)
+	txs, err := j.Transactions()
+	require.NoError(t, err)
+
+	var payees []string
+	for _, tx := range txs {
+		payees = append(payees, tx.Payee())
+	}
+	assert.Equal(t, []string{"Amazon", "Amazon", "Hydro-Quebec", "Library", "Grocery"}, payees)
+
+	postings := txs[4].Postings()
+	assert.Equal(t, "Grocery", postings[0].Payee())
+	assert.Equal(t, "Bakery", postings[1].Payee())
+	assert.Equal(t, "Library", txs[3].Postings()[0].Payee())
+
+	declared, err := j.DeclaredPayees()
+	require.NoError(t, err)
+	require.Len(t, declared, 2)
+	assert.Equal(t, "Hydro-Quebec", declared[1].Payee)
+}
+
+func TestStyles(t *testing.T) {
+	j := newJournal(t, `D $1,000.00
+commodity EUR
+  format 1.000,00 EUR
+
+2016/09/10 Opening
+  Assets:Bank       $12345.678
+  Assets:Euros      2500 EUR
+  Assets:Cash       1,500 CAD
+  Assets:Cash       -0.5 CAD
+  Assets:Broker     10 AAPL @ $140.50
+  Equity
+`)
+	styles := j.Styles()
+	assert.Equal(t, "$", styles.Default)
+	assert.Equal(t, &Style{Prefix: true, Thousands: ",", Decimal: ".", Precision: 2, declared: true}, styles.Style("$"))
+	assert.Equal(t, &Style{Spaced: true, Thousands: ".", Decimal: ",", Precision: 2, declared: true}, styles.Style("EUR"))
+	assert.Equal(t, &Style{Spaced: true, Thousands: ",", Decimal: ".", Precision: 1}, styles.Style("CAD"))
+	assert.Equal(t, &Style{Spaced: true, Decimal: ".", Precision: 0}, styles.Style("AAPL"))
+	assert.Nil(t, styles.Style("USD"))
+
+	txs, err := j.Transactions()
+	require.NoError(t, err)
+	require.Len(t, txs, 1)
+	var amounts []string
+	for _, p := range txs[0].Postings()[:5] {
+		amounts = append(amounts, p.Amount().String())
+	}
+	assert.Equal(t, []string{"$12,345.68", "2.500,00 EUR", "1,500.0 CAD", "-0.5 CAD", "10 AAPL"}, amounts)
+
+	dollars := styles.Style("$")
+	assert.Equal(t, "$-1,234,567.00", Amount{Commodity: "$", Quantity: big.NewRat(-1234567, 1), Style: dollars}.String())
+	assert.Equal(t, "$0.00", Amount{Commodity: "$", Quantity: big.NewRat(-1, 1000), Style: dollars}.String())
+	assert.Equal(t, "0.3333333333 USD", Amount{Commodity: "USD", Quantity: big.NewRat(1, 3)}.String())
+}
+
+func TestDefaultCommodity(t *testing.T) {
+	j := newJournal(t, `2016/09/09 Before
+  Expenses:Food     5
+  Assets:Cash
+
+D $1,000.00
+
+2016/09/10 Grocery
+  Expenses:Food     1234.5
+  Expenses:Wine     10 CAD @ 0.75
+  Assets:Cash
+
+D 1.000,00 EUR
+
+2016/09/11 Dinner
+  Expenses:Food     20,5
+  Assets:Cash      -20,50 EUR
+`)
+	txs, err := j.Transactions()
+	require.NoError(t, err)
+	var amounts []string
+	for _, tx := range txs {
+		for _, p := range tx.Postings() {
+			amounts = append(amounts, p.Amount().String())
+		}
+	}
+	assert.Equal(t, []string{"5", "-5", "$1,234.50", "10 CAD", "$-1,242.00", "20,50 EUR", "-20,50 EUR"}, amounts)
+
+	price, err := txs[1].Postings()[1].Price()
+	require.NoError(t, err)
+	assert.Equal(t, "$", price.Commodity)
+
+	errs, err := j.Validate()
+	require.NoError(t, err)
+	assert.Empty(t, errs)
+}
+
+func TestDefaultCommodityErrors(t *testing.T) {
+	j := newJournal(t, `D $1,000.00
+
+2016/09/09 Opening
+  Assets:Cash     1000
+  Equity
+
+2016/09/10 Grocery
+  Expenses:Food   25
+  Assets:Cash     0 = 900
+
+2016/09/11 Dinner
+  Expenses:Food   (10 * 2)
+  Assets:Cash     -15
+`)
+	txs, err := j.Transactions()
+	require.NoError(t, err)
+	require.Len(t, txs, 3)
+	assert.Equal(t, "$20.00", txs[2].Postings()[0].Amount().String())
+
+	errs, err := j.Validate()
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"file.ledger:7:1: transaction does not balance, off by $25.00",
+		"file.ledger:11:1: transaction does not balance, off by $5.00",
+		"file.ledger:9:3: balance assertion failed for account 'Assets:Cash': expected $900.00, got $1,000.00 (difference: $100.00)",
+	}, errorStrings(errs))
+}
+
+func TestDecimalComma(t *testing.T) {
+	postingAmounts := func(j *Journal) []string {
+		txs, err := j.Transactions()
+		require.NoError(t, err)
+		var amounts []string
+		for _, tx := range txs {
+			for _, p := range tx.Postings() {
+				amounts = append(amounts, p.Amount().String())
+			}
+		}
+		return amounts
+	}
+
+	j := newJournal(t, `commodity EUR
+  format 1.000,00 EUR
+
+2024/03/01 Gehalt
+  Assets:Girokonto      1.234,56 EUR
+  Assets:Depot          1,5 EUR = 1,50 EUR
+  Assets:Bank           1,000.25 USD
+  Income:Gehalt        -1.236,06 EUR
+  Income:Bonus
+`)
+	assert.Equal(t, []string{"1.234,56 EUR", "1,50 EUR", "1,000.25 USD", "-1.236,06 EUR", "-1,000.25 USD"}, postingAmounts(j))
+	errs, err := j.Validate()
+	require.NoError(t, err)
+	assert.Empty(t, errs)
+
+	input := `2024/03/02 Einkauf
+  Expenses:Food    12,50 CHF
+  Expenses:Rent    1.000 CHF
+  Assets:Cash
+`
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "main.ledger"), []byte("include inc.ledger\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "inc.ledger"), []byte(input), 0644))
+	j, err = Open(filepath.Join(dir, "main.ledger"), OpenOptions{DecimalComma: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"12,50 CHF", "1000,00 CHF", "-1012,50 CHF"}, postingAmounts(j))
+
+	j = newJournal(t, input)
+	assert.Equal(t, []string{"1,250.000 CHF", "1.000 CHF", "-1,251.000 CHF"}, postingAmounts(j))
+}
+
+func TestPeriodAt(t *testing.T) {
+	j := newJournal(t, `~ Monthly this year
+  Expenses:Food  500 CAD
+  Assets
+`)
+	pts, err := j.PeriodicTransactions()
+	require.NoError(t, err)
+	require.Len(t, pts, 1)
+
+	p := pts[0].PeriodAt(time.Date(2017, time.June, 15, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2017, time.January, 1, 0, 0, 0, 0, time.UTC), p.Begin)
+	assert.Equal(t, time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC), p.End)
+	assert.Equal(t, 1, p.Every)
+}
@@ -0,0 +1,346 @@
+package journal
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/abourget/ledger/lpath"
+)
+
+// LotMethod tells which lots a sale takes from, when its posting does
+// not designate them with a lot price or a lot date.
+type LotMethod int
+
+const (
+	FIFO LotMethod = iota // The oldest lots first.
+	LIFO                  // The most recent lots first.
+)
+
+// Lot is a quantity of a commodity acquired at once, at a cost per
+// unit, and held in an account.
+type Lot struct {
+	Account   string
+	Commodity string
+	Quantity  *big.Rat  // Quantity still held, after the sales.
+	Cost      *Amount   // Cost per unit, from the lot price or else the price of the posting.
+	Date      time.Time // Date of acquisition, from the lot date or else the transaction date.
+	Posting   *Posting  // Posting acquiring the lot.
+}
+
+// Sale is the disposal of a quantity of a lot, realizing a gain.
+type Sale struct {
+	Lot      *Lot
+	Quantity *big.Rat
+	Date     time.Time
+	Proceeds *Amount  // Sale price times Quantity.
+	Cost     *Amount  // Lot cost times Quantity, the cost basis.
+	Gain     *Amount  // Proceeds less Cost, negative for a loss.
+	Posting  *Posting // Posting selling the lot.
+}
+
+// Holding is an open lot valued at a market price.
+type Holding struct {
+	Lot   *Lot
+	Cost  *Amount // Lot cost times the quantity held, the cost basis.
+	Value *Amount // Market value of the quantity held.
+	Gain  *Amount // Value less Cost, negative for a loss.
+}
+
+// Lots are the lots of a journal, as tracked by Journal.Lots.
+type Lots struct {
+	Open  []*Lot  // Lots still held, by date of acquisition.
+	Sales []*Sale // Sales, by date.
+}
+
+// Lots walks through the transactions in date order, and tracks the
+// lots of commodities held per account.  A posting acquiring a
+// commodity at a cost, with a lot price (`{}`) or a price (`@` or
+// `@@`), opens a lot.  A posting reducing a commodity held in lots
+// takes from the lots designated by its lot price and lot date, if any,
+// in the order given by method.  It realizes a gain when it has a
+// price; otherwise, like a transfer between accounts, it moves the lots
+// to the postings of the transaction receiving the commodity without a
+// cost in accounts of the same kind, keeping their cost and date, see
+// receive.  The quantities reduced beyond the lots held, like those in
+// accounts without lots, are not tracked, but reducing designated lots
+// beyond their quantity is an error.
+func (j *Journal) Lots(method LotMethod) (*Lots, error) {
+	txs, err := j.Transactions()
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(txs, func(i, k int) bool {
+		return txs[i].Node.Date.Before(txs[k].Node.Date)
+	})
+
+	l := &Lots{}
+	for _, tx := range txs {
+		var moved []*Lot       // lots transferred out by the transaction
+		var received []*Amount // amounts received without a cost
+		var receivers []*Posting
+		for _, p := range tx.Postings() {
+			amount := p.Amount()
+			if amount == nil || amount.Commodity == "" {
+				continue
+			}
+
+			var err error
+			switch amount.Quantity.Sign() {
+			case 1:
+				var opened bool
+				opened, err = l.acquire(p, amount)
+				if err == nil && !opened {
+					received = append(received, amount)
+					receivers = append(receivers, p)
+				}
+			case -1:
+				var out []*Lot
+				out, err = l.reduce(p, amount, method)
+				moved = append(moved, out...)
+			}
+			if err != nil {
+				return nil, nodeError(j.tree, p.Node, "%s", err)
+			}
+		}
+		for i, p := range receivers {
+			l.receive(p, received[i], moved)
+		}
+	}
+	return l, nil
+}
+
+// lotCost returns the cost per unit of the lot acquired or designated
+// by the posting, or nil.
+func lotCost(p *Posting) (*Amount, error) {
+	cost, err := p.Price()
+	if n := p.Node.LotPrice; n != nil && n.Commodity != "" {
+		cost, err = p.Transaction.journal.amountFromNode(n, nil)
+	}
+	return p.Transaction.styled(cost), err
+}
+
+// acquire opens a lot for the posting, and tells whether it did: the
+// posting has no cost otherwise.
+func (l *Lots) acquire(p *Posting, amount *Amount) (bool, error) {
+	cost, err := lotCost(p)
+	if err != nil || cost == nil || cost.Commodity == amount.Commodity {
+		return false, err
+	}
+
+	date := p.Node.LotDate
+	if date.IsZero() {
+		date = p.Transaction.Node.Date
+	}
+	l.open(&Lot{
+		Account:   p.Account(),
+		Commodity: amount.Commodity,
+		Quantity:  new(big.Rat).Set(amount.Quantity),
+		Cost:      cost,
+		Date:      date,
+		Posting:   p,
+	})
+	return true, nil
+}
+
+// open inserts lot in the open lots, by date of acquisition.
+func (l *Lots) open(lot *Lot) {
+	i := sort.Search(len(l.Open), func(i int) bool { return l.Open[i].Date.After(lot.Date) })
+	l.Open = append(l.Open, nil)
+	copy(l.Open[i+1:], l.Open[i:])
+	l.Open[i] = lot
+}
+
+// receive opens lots in the account of the posting for the quantities
+// of moved it receives, taking them in order.  Only the accounts under
+// the same top-level account as the lots receive them, like
+// "Assets:IRA" from "Assets:Broker": the lots spent, like to
+// "Expenses:Travel", are only closed.
+func (l *Lots) receive(p *Posting, amount *Amount, moved []*Lot) {
+	remaining := new(big.Rat).Set(amount.Quantity)
+	for _, lot := range moved {
+		if remaining.Sign() == 0 {
+			break
+		}
+		if lot.Commodity != amount.Commodity || lot.Quantity.Sign() == 0 || topAccount(lot.Account) != topAccount(p.Account()) {
+			continue
+		}
+		q := new(big.Rat).Set(lot.Quantity)
+		if q.Cmp(remaining) > 0 {
+			q.Set(remaining)
+		}
+		lot.Quantity.Sub(lot.Quantity, q)
+		remaining.Sub(remaining, q)
+
+		l.open(&Lot{
+			Account:   p.Account(),
+			Commodity: lot.Commodity,
+			Quantity:  q,
+			Cost:      lot.Cost,
+			Date:      lot.Date,
+			Posting:   lot.Posting,
+		})
+	}
+}
+
+// topAccount returns the first part of an account name, like "Assets".
+func topAccount(name string) string {
+	return strings.SplitN(name, lpath.Separator, 2)[0]
+}
+
+// reduce takes the quantity of amount out of the lots of the account of
+// the posting, and leaves the rest untracked, unless the posting
+// designates lots.  Without a price, it returns the quantities taken out of
+// each lot, to be received by other postings.
+func (l *Lots) reduce(p *Posting, amount *Amount, method LotMethod) ([]*Lot, error) {
+	designated := p.Node.LotPrice != nil || !p.Node.LotDate.IsZero()
+
+	var candidates []*Lot
+	held := false
+	for _, lot := range l.Open {
+		if lot.Account != p.Account() || lot.Commodity != amount.Commodity {
+			continue
+		}
+		held = true
+		match, err := matchesLot(p, lot)
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			candidates = append(candidates, lot)
+		}
+	}
+	if !held && !designated {
+		return nil, nil
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no lot of %s matching %s in %s", amount.Commodity, lotLabel(p), p.Account())
+	}
+
+	remaining := new(big.Rat).Neg(amount.Quantity)
+	available := new(big.Rat)
+	for _, lot := range candidates {
+		available.Add(available, lot.Quantity)
+	}
+	if designated && available.Cmp(remaining) < 0 {
+		sold := &Amount{Commodity: amount.Commodity, Quantity: remaining, Style: amount.Style}
+		inLots := &Amount{Commodity: amount.Commodity, Quantity: available, Style: amount.Style}
+		return nil, fmt.Errorf("cannot reduce %s in %s: only %s held in lots", sold, p.Account(), inLots)
+	}
+	if method == LIFO {
+		for i, k := 0, len(candidates)-1; i < k; i, k = i+1, k-1 {
+			candidates[i], candidates[k] = candidates[k], candidates[i]
+		}
+	}
+
+	price, err := p.Price()
+	if err != nil {
+		return nil, err
+	}
+	price = p.Transaction.styled(price)
+
+	var moved []*Lot
+	for _, lot := range candidates {
+		if remaining.Sign() == 0 {
+			break
+		}
+		q := new(big.Rat).Set(lot.Quantity)
+		if q.Cmp(remaining) > 0 {
+			q.Set(remaining)
+		}
+		lot.Quantity.Sub(lot.Quantity, q)
+		remaining.Sub(remaining, q)
+
+		if price == nil {
+			moved = append(moved, &Lot{
+				Account:   lot.Account,
+				Commodity: lot.Commodity,
+				Quantity:  q,
+				Cost:      lot.Cost,
+				Date:      lot.Date,
+				Posting:   lot.Posting,
+			})
+			continue
+		}
+		if price.Commodity != lot.Cost.Commodity {
+			return nil, fmt.Errorf("cannot realize a gain on %s: sold in %s, acquired in %s", amount.Commodity, price.Commodity, lot.Cost.Commodity)
+		}
+		cost := &Amount{Commodity: lot.Cost.Commodity, Quantity: new(big.Rat).Mul(lot.Cost.Quantity, q), Style: lot.Cost.Style}
+		proceeds := &Amount{Commodity: price.Commodity, Quantity: new(big.Rat).Mul(price.Quantity, q), Style: price.Style}
+		l.Sales = append(l.Sales, &Sale{
+			Lot:      lot,
+			Quantity: q,
+			Date:     p.Transaction.Node.Date,
+			Proceeds: proceeds,
+			Cost:     cost,
+			Gain:     &Amount{Commodity: cost.Commodity, Quantity: new(big.Rat).Sub(proceeds.Quantity, cost.Quantity), Style: cost.Style},
+			Posting:  p,
+		})
+	}
+	open := l.Open[:0]
+	for _, lot := range l.Open {
+		if lot.Quantity.Sign() != 0 {
+			open = append(open, lot)
+		}
+	}
+	l.Open = open
+	return moved, nil
+}
+
+// matchesLot tells whether lot is designated by the lot price and the
+// lot date of the posting, if any.
+func matchesLot(p *Posting, lot *Lot) (bool, error) {
+	if n := p.Node.LotPrice; n != nil {
+		cost, err := p.Transaction.journal.amountFromNode(n, nil)
+		if err != nil {
+			return false, err
+		}
+		if cost.Quantity.Cmp(lot.Cost.Quantity) != 0 || cost.Commodity != "" && cost.Commodity != lot.Cost.Commodity {
+			return false, nil
+		}
+	}
+	if d := p.Node.LotDate; !d.IsZero() && !d.Equal(lot.Date) {
+		return false, nil
+	}
+	return true, nil
+}
+
+// lotLabel describes the lots designated by a posting, like
+// "{140 USD} [2017/05/01]".
+func lotLabel(p *Posting) string {
+	var label string
+	if n := p.Node.LotPrice; n != nil {
+		label = n.String()
+	}
+	if d := p.Node.LotDate; !d.IsZero() {
+		if label != "" {
+			label += " "
+		}
+		label += "[" + d.Format("2006/01/02") + "]"
+	}
+	return label
+}
+
+// Unrealized values the open lots at time at, using price to find the
+// price of a commodity in another, like prices.PriceDB.Price.  The lots
+// without a price in the commodity of their cost are left out.
+func (l *Lots) Unrealized(at time.Time, price func(commodity, target string, at time.Time) (*big.Rat, bool)) []*Holding {
+	var holdings []*Holding
+	for _, lot := range l.Open {
+		p, ok := price(lot.Commodity, lot.Cost.Commodity, at)
+		if !ok {
+			continue
+		}
+		cost := &Amount{Commodity: lot.Cost.Commodity, Quantity: new(big.Rat).Mul(lot.Cost.Quantity, lot.Quantity), Style: lot.Cost.Style}
+		value := &Amount{Commodity: lot.Cost.Commodity, Quantity: new(big.Rat).Mul(p, lot.Quantity), Style: lot.Cost.Style}
+		holdings = append(holdings, &Holding{
+			Lot:   lot,
+			Cost:  cost,
+			Value: value,
+			Gain:  &Amount{Commodity: cost.Commodity, Quantity: new(big.Rat).Sub(value.Quantity, cost.Quantity), Style: cost.Style},
+		})
+	}
+	return holdings
+}
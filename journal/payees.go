@@ -0,0 +1,98 @@
+package journal
+
+import (
+	"regexp"
+
+	"github.com/abourget/ledger/parse"
+)
+
+// payees resolves the descriptions of the transactions to the payees
+// declared with the `payee` directive, see Transaction.Payee.
+type payees struct {
+	aliases []payeeAlias
+	uuids   map[string]string
+}
+
+type payeeAlias struct {
+	re    *regexp.Regexp
+	payee string
+}
+
+// DeclaredPayees returns the payees declared with the `payee`
+// directive in the journal and its included journals.
+func (j *Journal) DeclaredPayees() ([]*parse.PayeeNode, error) {
+	payees := make([]*parse.PayeeNode, 0)
+	err := j.walk(func(n parse.Node) error {
+		if p, ok := n.(*parse.PayeeNode); ok {
+			payees = append(payees, p)
+		}
+		return nil
+	})
+	return payees, err
+}
+
+// payees returns the resolver of the payees, computing it on first
+// use.  Like in Ledger, the aliases are case-insensitive, and match
+// anywhere in the description unless anchored.
+func (j *Journal) payees() *payees {
+	if j.resolvedPayees != nil {
+		return j.resolvedPayees
+	}
+	r := &payees{uuids: make(map[string]string)}
+	j.resolvedPayees = r
+
+	// Errors are reported when listing the transactions.
+	nodes, _ := j.DeclaredPayees()
+	for _, n := range nodes {
+		for _, alias := range n.Aliases {
+			// The parser rejects invalid aliases.
+			if re, err := regexp.Compile("(?i)" + alias); err == nil {
+				r.aliases = append(r.aliases, payeeAlias{re: re, payee: n.Payee})
+			}
+		}
+		if n.UUID != "" {
+			r.uuids[n.UUID] = n.Payee
+		}
+	}
+	return r
+}
+
+// resolve returns the payee of a transaction described by desc, with
+// the `UUID` metadata uuid, if any.
+func (r *payees) resolve(desc, uuid string) string {
+	if payee, ok := r.uuids[uuid]; ok && uuid != "" {
+		return payee
+	}
+	for _, a := range r.aliases {
+		if a.re.MatchString(desc) {
+			return a.payee
+		}
+	}
+	return desc
+}
+
+// Payee returns the payee of the transaction: the value of its `Payee`
+// metadata, if any, or else the payee declared with a `payee`
+// directive whose `uuid` is its `UUID` metadata, or one of whose
+// `alias` regexps matches its description, like "AMZN Mktp US*2K4" for
+// `alias ^AMZN`, in the order of the directives.  Otherwise, it is the
+// description.
+func (tx *Transaction) Payee() string {
+	tags := tx.Tags()
+	if payee := tags["Payee"].Raw; payee != "" {
+		return payee
+	}
+	if tx.journal == nil {
+		return tx.Node.Description
+	}
+	return tx.journal.payees().resolve(tx.Node.Description, tags["UUID"].Raw)
+}
+
+// Payee returns the payee of the posting: the value of its own `Payee`
+// metadata, if any, or else the payee of its transaction.
+func (p *Posting) Payee() string {
+	if payee := parseTags(p.Node.Note)["Payee"].Raw; payee != "" {
+		return payee
+	}
+	return p.Transaction.Payee()
+}
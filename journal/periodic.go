@@ -0,0 +1,49 @@
+package journal
+
+import (
+	"time"
+
+	"github.com/abourget/ledger/parse"
+	"github.com/abourget/ledger/period"
+)
+
+// PeriodicTransaction is a transaction recurring over a period, like
+// `~ Monthly`. It is mostly used to express budgets.
+type PeriodicTransaction struct {
+	Node *parse.PeriodicXactNode
+
+	journal *Journal // used to resolve account names, can be nil
+}
+
+// Period returns the period of pt, with its relative dates, like "this
+// month", resolved when the journal was parsed.  See PeriodAt.
+func (pt *PeriodicTransaction) Period() *period.Period {
+	return pt.Node.Period
+}
+
+// PeriodAt returns the period of pt, with its relative dates resolved
+// against now, like the date of a report.
+func (pt *PeriodicTransaction) PeriodAt(now time.Time) *period.Period {
+	p, err := period.ParseAt(pt.Node.PeriodExpr, now)
+	if err != nil {
+		// The parser checked the expression already, only relative
+		// dates can make its range end before it begins.
+		return pt.Node.Period
+	}
+	return p
+}
+
+// At returns the occurrence of the periodic transaction on `date`, as
+// a regular transaction sharing the postings of pt.
+func (pt *PeriodicTransaction) At(date time.Time) *Transaction {
+	n := &parse.XactNode{NodeType: parse.NodeXact}
+	n.Date = date
+	n.Description = pt.Node.PeriodExpr
+	n.Note = pt.Node.Note
+	n.Postings = pt.Node.Postings
+	return &Transaction{Node: n, journal: pt.journal}
+}
+
+func (pt *PeriodicTransaction) Postings() []*Posting {
+	return pt.At(time.Time{}).Postings()
+}
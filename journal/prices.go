@@ -0,0 +1,102 @@
+package journal
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/abourget/ledger/parse"
+	"github.com/abourget/ledger/valexpr"
+)
+
+// Price is the price of one unit of a commodity at a point in time, as
+// declared with a `P` directive.
+type Price struct {
+	Date      time.Time
+	Commodity string
+	Price     *Amount
+	Node      *parse.PriceNode
+}
+
+// Prices returns the prices declared with `P` directives in the journal
+// and its included journals, in the order of the files.
+func (j *Journal) Prices() ([]*Price, error) {
+	prices := make([]*Price, 0)
+	err := j.walk(func(n parse.Node) error {
+		p, ok := n.(*parse.PriceNode)
+		if !ok {
+			return nil
+		}
+		a, err := j.amountFromNode(p.Price, nil)
+		if err != nil {
+			return nodeError(j.tree, p, "%s", err)
+		}
+		prices = append(prices, &Price{Date: p.Date, Commodity: p.Commodity, Price: a, Node: p})
+		return nil
+	})
+	return prices, err
+}
+
+// PriceSource gives the value of one unit of a commodity in a target
+// commodity at a point in time, like prices.PriceDB.
+type PriceSource interface {
+	Price(commodity, target string, at time.Time) (*big.Rat, bool)
+}
+
+// SetPrices sets the prices used by the `market` function of the value
+// expressions in the amounts of the postings.  Without prices, `market`
+// fails.
+func (j *Journal) SetPrices(prices PriceSource) {
+	j.prices = prices
+	j.resetBalances()
+}
+
+// market converts a to the target commodity at time at, for the
+// `market` function of value expressions.  An empty target means the
+// default commodity, see Styles.
+func (j *Journal) market(a valexpr.Amount, at time.Time, target string) (valexpr.Amount, error) {
+	if j == nil || j.prices == nil {
+		return valexpr.Amount{}, fmt.Errorf("no prices to value %s", a)
+	}
+	if target == "" {
+		target = j.Styles().Default
+	}
+	if target == "" {
+		return valexpr.Amount{}, fmt.Errorf("no commodity to value %s in, and no default commodity", a)
+	}
+	price, ok := j.prices.Price(a.Commodity, target, at)
+	if !ok {
+		return valexpr.Amount{}, fmt.Errorf("no price of %s in %s on %s", a.Commodity, target, at.Format("2006/01/02"))
+	}
+	return valexpr.Amount{Quantity: new(big.Rat).Mul(a.Quantity, price), Commodity: target}, nil
+}
+
+// Price returns the price of one unit of the posting's amount, written
+// after `@`, or derived from the price of the whole amount written
+// after `@@`.  It is nil when the posting has no such price.
+func (p *Posting) Price() (*Amount, error) {
+	n := p.Node
+	if n.Price == nil || n.Amount == nil {
+		return nil, nil
+	}
+	j := p.Transaction.journal
+	price, err := j.amountFromNode(n.Price, nil)
+	if err != nil {
+		return nil, err
+	}
+	price.Quantity.Abs(price.Quantity)
+	if !n.PriceIsForWhole {
+		return price, nil
+	}
+
+	amount, err := j.amountFromNode(n.Amount, p.Transaction.valueEnv(n))
+	if err != nil {
+		return nil, err
+	}
+	if amount.Quantity.Sign() == 0 {
+		return nil, nil
+	}
+	q := new(big.Rat).Abs(amount.Quantity)
+	price.Quantity.Quo(price.Quantity, q)
+	return price, nil
+}
@@ -0,0 +1,57 @@
+package journal
+
+import "github.com/abourget/ledger/parse"
+
+// OpenOptions tunes how a journal is read, and the checks run when
+// opening it.
+type OpenOptions struct {
+	// Strict reports, in Journal.Warnings, the postings using an
+	// account or a commodity not declared beforehand with the
+	// `account` or `commodity` directives.
+	Strict bool
+	// Pedantic makes Open fail on those same postings.
+	Pedantic bool
+	// DecimalComma reads the quantities like "1.234,56", with a
+	// decimal comma, unless the format of their commodity says
+	// otherwise.  It applies to the included journals too.
+	DecimalComma bool
+}
+
+// CheckDeclarations reports the postings to accounts, and the amounts
+// in commodities, not previously declared with `account` or
+// `commodity` directives, in the order of the files.
+func (j *Journal) CheckDeclarations() (ErrorList, error) {
+	var errs ErrorList
+	accounts := make(map[string]bool)
+	commodities := make(map[string]bool)
+
+	err := j.walk(func(n parse.Node) error {
+		switch node := n.(type) {
+		case *parse.AccountNode:
+			accounts[node.Account] = true
+		case *parse.CommodityNode:
+			commodities[node.Commodity] = true
+			if node.Alias != "" {
+				commodities[node.Alias] = true
+			}
+		case *parse.DefaultCommodityNode:
+			commodities[node.Amount.Commodity] = true
+		case *parse.XactNode:
+			tx := &Transaction{Node: node, journal: j}
+			for _, p := range node.Postings {
+				account := tx.account(p)
+				if !accounts[account] {
+					errs = append(errs, nodeError(j.tree, p, "unknown account '%s'", account))
+				}
+				for _, a := range []*parse.AmountNode{p.Amount, p.Price, p.BalanceAssertion, p.BalanceAssignment} {
+					if a == nil || a.Commodity == "" || commodities[a.Commodity] {
+						continue
+					}
+					errs = append(errs, nodeError(j.tree, p, "unknown commodity '%s'", a.Commodity))
+				}
+			}
+		}
+		return nil
+	})
+	return errs, err
+}
@@ -0,0 +1,271 @@
+package journal
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/abourget/ledger/parse"
+)
+
+// Style is the way the amounts of a commodity are written, like
+// "$1,000.00" or "10.5 CAD".
+type Style struct {
+	Prefix    bool   // Commodity before the quantity, like "$10".
+	Spaced    bool   // Space between the commodity and the quantity, like "10 CAD".
+	Thousands string // Separator of the groups of thousands, like "," in "1,000", or empty.
+	Decimal   string // Decimal mark, "." or ",".
+	Precision int    // Number of decimals shown, rounding the quantity. Negative shows as many as needed, up to 10.
+
+	declared bool // Given by a directive, rather than learned from the amounts.
+}
+
+// defaultStyle writes the amounts of the commodities without a style,
+// like "10.5 CAD".
+var defaultStyle = &Style{Spaced: true, Decimal: ".", Precision: -1}
+
+// Format writes a quantity of commodity in the style s.
+func (s *Style) Format(commodity string, q *big.Rat) string {
+	var digits string
+	if s.Precision < 0 {
+		digits = strings.TrimRight(q.FloatString(10), "0")
+		digits = strings.TrimRight(digits, ".")
+	} else {
+		digits = q.FloatString(s.Precision)
+	}
+	negative := strings.HasPrefix(digits, "-")
+	digits = strings.TrimPrefix(digits, "-")
+
+	integer, decimals := digits, ""
+	if i := strings.Index(digits, "."); i >= 0 {
+		integer, decimals = digits[:i], digits[i+1:]
+	}
+	if s.Thousands != "" {
+		for i := len(integer) - 3; i > 0; i -= 3 {
+			integer = integer[:i] + s.Thousands + integer[i:]
+		}
+	}
+	number := integer
+	if decimals != "" {
+		number += s.Decimal + decimals
+	}
+	if negative && strings.Trim(digits, "0.") != "" {
+		number = "-" + number
+	}
+
+	if commodity == "" {
+		return number
+	}
+	space := ""
+	if s.Spaced {
+		space = " "
+	}
+	if s.Prefix {
+		return commodity + space + number
+	}
+	return number + space + commodity
+}
+
+// styleOf returns the style of an amount of commodity as written, like
+// "$1,000.00", "- 10 CAD" or "1.000,00 EUR", see numberStyle for
+// decimal.
+func styleOf(commodity, amount, decimal string) *Style {
+	s := &Style{}
+	amount = strings.TrimLeft(strings.TrimSpace(amount), "- \t")
+
+	var number string
+	if strings.HasPrefix(amount, commodity) {
+		s.Prefix = true
+		number = amount[len(commodity):]
+		s.Spaced = strings.IndexAny(number, " \t") == 0
+		number = strings.TrimLeft(number, "- \t")
+	} else {
+		number = amount
+		if i := strings.LastIndex(amount, commodity); i >= 0 {
+			number = amount[:i]
+		}
+		s.Spaced = strings.TrimRight(number, " \t") != number
+		number = strings.TrimSpace(number)
+	}
+	s.Thousands, s.Decimal, s.Precision = numberStyle(number, decimal)
+	return s
+}
+
+// numberStyle returns the separator of the thousands, the decimal mark
+// and the number of decimals of a quantity as written, like "1,000.00".
+// With both a period and a comma, the last one is the decimal mark, like
+// in "1.000,00".  Otherwise, the one written is the decimal mark if it
+// is decimal, "." or ",", and the separator of the thousands if not.
+func numberStyle(number, decimal string) (thousands, mark string, precision int) {
+	period, comma := strings.LastIndex(number, "."), strings.LastIndex(number, ",")
+	switch {
+	case period >= 0 && comma > period:
+		thousands, mark = ".", ","
+	case comma >= 0 && period > comma:
+		thousands, mark = ",", "."
+	case period >= 0 && decimal == ",":
+		thousands, mark = ".", ","
+	case comma >= 0 && decimal == ".":
+		thousands, mark = ",", "."
+	default:
+		mark = decimal
+	}
+	if i := strings.LastIndex(number, mark); i >= 0 {
+		precision = len(number) - i - 1
+	}
+	return thousands, mark, precision
+}
+
+// parseQuantity parses a quantity as written, like "1,234.56", or
+// "1.234,56" when decimal is ",", see numberStyle.
+func parseQuantity(quantity, decimal string) (*big.Rat, error) {
+	thousands, mark, _ := numberStyle(quantity, decimal)
+	q := quantity
+	if thousands != "" {
+		q = strings.Replace(q, thousands, "", -1)
+	}
+	q = strings.Replace(q, mark, ".", 1)
+	r, ok := new(big.Rat).SetString(q)
+	if !ok {
+		return nil, fmt.Errorf("cannot parse quantity: %s", quantity)
+	}
+	return r, nil
+}
+
+// decimalMark returns the decimal mark of the quantities of commodity:
+// the one of its format, if declared with a `D` or `commodity`
+// directive, or else "," with OpenOptions.DecimalComma, or ".".
+func (j *Journal) decimalMark(commodity string) string {
+	if j == nil {
+		return "."
+	}
+	if s := j.Styles().Style(commodity); s != nil && s.declared {
+		return s.Decimal
+	}
+	if j.decimalComma {
+		return ","
+	}
+	return "."
+}
+
+// decimals returns the number of decimals written in the quantity of
+// n, like 2 for "1,000.25".
+func (j *Journal) decimals(n *parse.AmountNode) int {
+	_, _, precision := numberStyle(n.Quantity, j.decimalMark(j.commodityOf(n)))
+	return precision
+}
+
+// commodityOf returns the commodity of the amount n, as written, or
+// else the default commodity in effect where it is written, if any.
+func (j *Journal) commodityOf(n *parse.AmountNode) string {
+	if n.Commodity != "" || j == nil {
+		return n.Commodity
+	}
+	return j.Styles().defaults[n]
+}
+
+// Styles are the styles of the commodities of a journal, see
+// Journal.Styles.
+type Styles struct {
+	Default string // Commodity of the latest `D` directive, or `commodity` directive with `default`, or empty.
+
+	styles   map[string]*Style
+	decimal  string                       // decimal mark of the quantities without a declared style, see numberStyle.
+	defaults map[*parse.AmountNode]string // default commodity of the amounts written without one
+}
+
+// Style returns the style of commodity, or nil if it is not known.
+func (s *Styles) Style(commodity string) *Style {
+	if s == nil {
+		return nil
+	}
+	return s.styles[commodity]
+}
+
+// declare sets the style of commodity, as given by a directive.
+func (s *Styles) declare(commodity string, style *Style) {
+	style.declared = true
+	s.styles[commodity] = style
+}
+
+// learn learns the style of commodity from an amount as written.  The
+// first amount gives the style, and the following ones only widen its
+// precision, like in Ledger.  An amount without a commodity gets the
+// current default one.
+func (s *Styles) learn(n *parse.AmountNode) {
+	if n != nil && n.Commodity == "" && s.Default != "" {
+		s.defaults[n] = s.Default
+	}
+	if n == nil || n.Commodity == "" || n.ValueExpr != "" || n.Raw == "" {
+		return
+	}
+	style := styleOf(n.Commodity, n.Raw, s.decimal)
+	current, ok := s.styles[n.Commodity]
+	switch {
+	case !ok:
+		s.styles[n.Commodity] = style
+	case !current.declared && style.Precision > current.Precision:
+		current.Precision = style.Precision
+	}
+}
+
+// Styles returns the styles of the commodities of the journal, given
+// by the `D` directives, the `format` of the `commodity` directives, or
+// else learned from the amounts written in the journal.  They are used
+// to write the amounts returned by Posting.Amount.  The amounts written
+// without a commodity get the one of the latest `D` directive, or
+// `commodity` directive with `default`, preceding them.
+func (j *Journal) Styles() *Styles {
+	if j.styles != nil {
+		return j.styles
+	}
+	s := &Styles{styles: make(map[string]*Style), decimal: ".", defaults: make(map[*parse.AmountNode]string)}
+	if j.decimalComma {
+		s.decimal = ","
+	}
+	j.styles = s
+
+	learnPostings := func(postings []*parse.PostingNode) {
+		for _, p := range postings {
+			s.learn(p.Amount)
+			s.learn(p.Price)
+			s.learn(p.BalanceAssertion)
+			s.learn(p.BalanceAssignment)
+		}
+	}
+
+	// Errors are reported when listing the transactions.
+	_ = j.walk(func(n parse.Node) error {
+		switch node := n.(type) {
+		case *parse.DefaultCommodityNode:
+			s.declare(node.Amount.Commodity, styleOf(node.Amount.Commodity, node.Amount.String(), s.decimal))
+			s.Default = node.Amount.Commodity
+		case *parse.CommodityNode:
+			if node.Format != "" {
+				s.declare(node.Commodity, styleOf(node.Commodity, node.Format, s.decimal))
+			}
+			if node.Default {
+				s.Default = node.Commodity
+			}
+		case *parse.PriceNode:
+			s.learn(node.Price)
+		case *parse.XactNode:
+			learnPostings(node.Postings)
+		case *parse.AutoXactNode:
+			learnPostings(node.Postings)
+		case *parse.PeriodicXactNode:
+			learnPostings(node.Postings)
+		}
+		return nil
+	})
+	return s
+}
+
+// styled sets the style of a, from the journal of the transaction, if
+// any, and returns it.
+func (tx *Transaction) styled(a *Amount) *Amount {
+	if a != nil && a.Style == nil && tx.journal != nil {
+		a.Style = tx.journal.Styles().Style(a.Commodity)
+	}
+	return a
+}
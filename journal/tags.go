@@ -0,0 +1,106 @@
+package journal
+
+import (
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TagValue is the value of a tag found in the notes of a transaction
+// or posting.  Plain tags, like `; :groceries:receipt:`, have an empty
+// value.  Metadata, like `; Payee: Bob`, have a string value, and
+// typed metadata, like `; Receipt:: "path"`, have their value parsed.
+type TagValue struct {
+	Raw   string      // Value as written after the ':' or '::', trimmed.
+	Typed bool        // Whether the value was given with '::'.
+	Value interface{} // Parsed value of typed metadata: a string, *big.Rat, time.Time or bool.
+}
+
+func (v TagValue) String() string {
+	return v.Raw
+}
+
+func (tx *Transaction) Tags() map[string]TagValue {
+	return parseTags(tx.Node.Note)
+}
+
+// Tags returns the tags of the posting, including the ones inherited
+// from its transaction.
+func (p *Posting) Tags() map[string]TagValue {
+	tags := p.Transaction.Tags()
+	for name, value := range parseTags(p.Node.Note) {
+		tags[name] = value
+	}
+	return tags
+}
+
+// parseTags reads the tags and metadata of a note, as found in
+// XactNode.Note or PostingNode.Note.
+func parseTags(note string) map[string]TagValue {
+	tags := make(map[string]TagValue)
+	for _, line := range strings.Split(note, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), ";#%|*"))
+		if line == "" {
+			continue
+		}
+
+		if key, value, ok := splitMetadata(line); ok {
+			tags[key] = value
+			continue
+		}
+
+		for _, word := range strings.Fields(line) {
+			if len(word) < 3 || !strings.HasPrefix(word, ":") || !strings.HasSuffix(word, ":") {
+				continue
+			}
+			for _, tag := range strings.Split(strings.Trim(word, ":"), ":") {
+				if tag != "" {
+					tags[tag] = TagValue{}
+				}
+			}
+		}
+	}
+	return tags
+}
+
+// splitMetadata splits up a line like "Key: value" or "Key:: value".
+func splitMetadata(line string) (string, TagValue, bool) {
+	word, rest := line, ""
+	if i := strings.IndexAny(line, " \t"); i >= 0 {
+		word, rest = line[:i], strings.TrimSpace(line[i:])
+	}
+	if strings.HasPrefix(word, ":") || !strings.HasSuffix(word, ":") {
+		return "", TagValue{}, false
+	}
+
+	value := TagValue{Raw: rest}
+	if strings.HasSuffix(word, "::") {
+		value.Typed = true
+		value.Value = parseTypedValue(rest)
+	}
+	key := strings.TrimRight(word, ":")
+	return key, value, key != ""
+}
+
+// parseTypedValue parses the value of typed metadata: a quoted string,
+// a number, a date within brackets or a boolean.  Anything else is
+// kept as a string.
+func parseTypedValue(raw string) interface{} {
+	if s, err := strconv.Unquote(raw); err == nil {
+		return s
+	}
+	if q, ok := new(big.Rat).SetString(strings.Replace(raw, ",", "", -1)); ok {
+		return q
+	}
+	if strings.HasPrefix(raw, "[") && strings.HasSuffix(raw, "]") {
+		date := strings.NewReplacer("/", "-", ".", "-").Replace(strings.Trim(raw, "[]"))
+		if t, err := time.ParseInLocation("2006-1-2", date, time.UTC); err == nil {
+			return t
+		}
+	}
+	if b, err := strconv.ParseBool(raw); err == nil {
+		return b
+	}
+	return raw
+}
@@ -2,21 +2,27 @@ package journal
 
 import (
 	"errors"
+	"fmt"
 	"math/big"
 	"strings"
 
 	"github.com/abourget/ledger/parse"
+	"github.com/abourget/ledger/valexpr"
 )
 
 var ErrInvalidAmount = errors.New("Unexpected type for amount given")
 
 type Transaction struct {
 	Node *parse.XactNode
+
+	journal *Journal // used to resolve balance assignments, can be nil
 }
 
+// Posting returns the first posting to account, written as is or as
+// returned by Posting.Account, or nil.
 func (tx *Transaction) Posting(account string) *Posting {
 	for _, n := range tx.Node.Postings {
-		if n.Account == account {
+		if n.Account == account || tx.account(n) == account {
 			return &Posting{n, tx}
 		}
 	}
@@ -38,24 +44,80 @@ func (tx *Transaction) NewPosting(account string) *Posting {
 	return &Posting{n, tx}
 }
 
+// ImplicitAmount returns the amount balancing the real postings of the
+// transaction, as given to a posting without amount. It returns nil
+// when that amount would span several commodities.
 func (tx *Transaction) ImplicitAmount() *Amount {
-	var amount *Amount
+	a, _ := tx.implicitAmount(realPosting)
+	return a
+}
+
+func (tx *Transaction) implicitAmount(kind postingKind) (*Amount, error) {
+	b := newBalancer(tx.journal)
+	var commodity string
 	for _, n := range tx.Node.Postings {
-		if n.Amount != nil {
-			a := nodeToAmount(n.Amount)
-			if amount != nil {
-				if amount.Commodity != a.Commodity {
-					return nil
-				}
-				amount.Quantity.Add(amount.Quantity, a.Quantity)
-			} else {
-				amount = a
-			}
+		if kindOf(n) != kind {
+			continue
+		}
+		a, err := tx.knownAmount(n)
+		if err != nil {
+			return nil, err
+		}
+		if a == nil {
+			continue
+		}
+		if err := b.add(n, a); err != nil {
+			return nil, err
+		}
+		if commodity == "" {
+			commodity = a.Commodity
 		}
 	}
 
-	amount.Quantity.Neg(amount.Quantity)
-	return amount
+	residuals := b.residuals()
+	switch len(residuals) {
+	case 0:
+		return &Amount{Commodity: commodity, Quantity: new(big.Rat)}, nil
+	case 1:
+		amount := residuals[0]
+		amount.Quantity.Neg(amount.Quantity)
+		return amount, nil
+	}
+	var msg []string
+	for _, r := range residuals {
+		r.Quantity.Neg(r.Quantity)
+		msg = append(msg, tx.styled(r).String())
+	}
+	return nil, fmt.Errorf("implicit amount spans several commodities: %s", strings.Join(msg, ", "))
+}
+
+// knownAmount returns the amount written in the posting n, or the
+// amount implied by its balance assignment. It returns nil for
+// postings without amount.
+func (tx *Transaction) knownAmount(n *parse.PostingNode) (*Amount, error) {
+	switch {
+	case n.Amount != nil:
+		return tx.journal.amountFromNode(n.Amount, tx.valueEnv(n))
+	case n.BalanceAssignment != nil:
+		if tx.journal == nil {
+			return nil, fmt.Errorf("cannot resolve balance assignment outside of a journal")
+		}
+		return tx.journal.balances().assigned[n], nil
+	}
+	return nil, nil
+}
+
+// valueEnv returns the variables available to the value expressions
+// of the posting n.
+func (tx *Transaction) valueEnv(n *parse.PostingNode) *valexpr.Env {
+	return &valexpr.Env{
+		Vars: map[string]valexpr.Value{
+			"date":    tx.Node.Date,
+			"payee":   tx.Node.Description,
+			"account": tx.account(n),
+		},
+		Market: tx.journal.market,
+	}
 }
 
 type Posting struct {
@@ -63,8 +125,11 @@ type Posting struct {
 	Transaction *Transaction
 }
 
+// Account returns the full name of the posting's account, after the
+// `alias` and `apply account` directives, without the brackets or
+// parentheses of virtual postings.
 func (p *Posting) Account() string {
-	return strings.Trim(p.Node.Account, "()[]")
+	return p.Transaction.account(p.Node)
 }
 
 func (p *Posting) SetAmount(commodity string, amount interface{}) error {
@@ -77,25 +142,80 @@ func (p *Posting) SetAmount(commodity string, amount interface{}) error {
 		p.Node.Amount = &parse.AmountNode{NodeType: parse.NodeAmount}
 	}
 	p.Node.Amount.Raw = ""
+	p.Node.Amount.ValueExpr = ""
 	p.Node.Amount.Commodity = commodity
 	p.Node.Amount.Quantity = v
+	if j := p.Transaction.journal; j != nil {
+		j.resetBalances()
+	}
 	return nil
 }
 
+// Amount returns the amount of the posting, whether written, implied by
+// a balance assignment or balancing the other postings, in the style of
+// its commodity.  It is nil when the amount cannot be known, see
+// ResolveAmount.
 func (p *Posting) Amount() *Amount {
-	if p.Node.Amount == nil {
-		return p.Transaction.ImplicitAmount()
+	a, _ := p.ResolveAmount()
+	return a
+}
+
+// ResolveAmount returns the amount of the posting, like Amount, or an
+// error located at the posting when it cannot be known: a value
+// expression failing to evaluate, or an amount balancing the other
+// postings in several commodities.
+func (p *Posting) ResolveAmount() (*Amount, error) {
+	tx := p.Transaction
+	var a *Amount
+	var err error
+	if p.Node.Amount != nil || p.Node.BalanceAssignment != nil {
+		a, err = tx.knownAmount(p.Node)
+	} else {
+		a, err = tx.implicitAmount(kindOf(p.Node))
+	}
+	if err != nil {
+		if tx.journal == nil {
+			return nil, err
+		}
+		return nil, nodeError(tx.journal.tree, p.Node, "%s", err)
 	}
-	return nodeToAmount(p.Node.Amount)
+	return tx.styled(a), nil
 }
 
-func nodeToAmount(n *parse.AmountNode) *Amount {
-	quant, ok := big.NewRat(0, 1).SetString(n.Quantity)
+// amountFromNode returns the amount written in n, evaluating its value
+// expression, if any, within env.  Its quantity is parsed with the
+// decimal mark of its commodity, see decimalMark; j can be nil for
+// amounts outside of a journal.
+func (j *Journal) amountFromNode(n *parse.AmountNode, env *valexpr.Env) (*Amount, error) {
+	if n.ValueExpr != "" {
+		a, err := evalAmount(n.ValueExpr, n.Negative, env)
+		if err == nil && a.Commodity == "" {
+			a.Commodity = j.commodityOf(n)
+		}
+		return a, err
+	}
+	commodity := j.commodityOf(n)
+	quant, err := parseQuantity(n.Quantity, j.decimalMark(commodity))
+	if err != nil {
+		return nil, err
+	}
 	if n.Negative {
 		quant.Neg(quant)
 	}
+	return &Amount{Commodity: commodity, Quantity: quant}, nil
+}
+
+func evalAmount(expr string, negative bool, env *valexpr.Env) (*Amount, error) {
+	v, err := valexpr.Eval(expr, env)
+	if err != nil {
+		return nil, err
+	}
+	a, ok := v.(valexpr.Amount)
 	if !ok {
-		panic("cannot parse quantity: " + n.Quantity)
+		return nil, fmt.Errorf("value expression %s does not evaluate to an amount", expr)
+	}
+	if negative {
+		a.Quantity.Neg(a.Quantity)
 	}
-	return &Amount{n.Commodity, quant}
+	return &Amount{Commodity: a.Commodity, Quantity: a.Quantity}, nil
 }
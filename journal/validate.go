@@ -0,0 +1,195 @@
+package journal
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/abourget/ledger/parse"
+)
+
+// postingKind tells how a posting takes part in the balance of its
+// transaction.
+type postingKind int
+
+const (
+	realPosting            postingKind = iota
+	balancedVirtualPosting             // [Account], must balance with the other bracketed postings.
+	virtualPosting                     // (Account), never balanced.
+)
+
+func kindOf(n *parse.PostingNode) postingKind {
+	switch {
+	case strings.HasPrefix(n.Account, "["):
+		return balancedVirtualPosting
+	case strings.HasPrefix(n.Account, "("):
+		return virtualPosting
+	}
+	return realPosting
+}
+
+// cost returns the weight of a posting's amount in the balance of its
+// transaction: the amount converted with its price (`@` or `@@`) or
+// else its lot price (`{}`), if any.
+func (j *Journal) cost(n *parse.PostingNode, amount *Amount) (*Amount, error) {
+	price := n.Price
+	perUnit := !n.PriceIsForWhole
+	if price == nil && n.LotPrice != nil && n.LotPrice.Commodity != "" {
+		price = n.LotPrice
+		perUnit = true
+	}
+	if price == nil {
+		return amount, nil
+	}
+
+	p, err := j.amountFromNode(price, nil)
+	if err != nil {
+		return nil, err
+	}
+	q := new(big.Rat).Abs(p.Quantity)
+	if perUnit {
+		q.Mul(q, amount.Quantity)
+	} else if amount.Quantity.Sign() < 0 {
+		q.Neg(q)
+	}
+	return &Amount{Commodity: p.Commodity, Quantity: q}, nil
+}
+
+// balancer sums up the amounts of a group of postings that must
+// balance, per commodity.
+type balancer struct {
+	sums      map[string]*big.Rat
+	precision map[string]int // largest number of decimals written in each commodity
+	null      *parse.PostingNode
+	journal   *Journal // used to parse the quantities, can be nil
+}
+
+func newBalancer(j *Journal) *balancer {
+	return &balancer{
+		sums:      make(map[string]*big.Rat),
+		precision: make(map[string]int),
+		journal:   j,
+	}
+}
+
+func (b *balancer) add(n *parse.PostingNode, amount *Amount) error {
+	if amount == nil {
+		if b.null != nil {
+			return errors.New("only one posting with null amount allowed per transaction")
+		}
+		b.null = n
+		return nil
+	}
+
+	for _, a := range []*parse.AmountNode{n.Amount, n.Price, n.LotPrice} {
+		if a == nil || a.ValueExpr != "" {
+			continue
+		}
+		if prec := b.journal.decimals(a); prec >= b.precision[a.Commodity] {
+			b.precision[a.Commodity] = prec
+		}
+	}
+
+	c, err := b.journal.cost(n, amount)
+	if err != nil {
+		return err
+	}
+	sum, ok := b.sums[c.Commodity]
+	if !ok {
+		sum = new(big.Rat)
+		b.sums[c.Commodity] = sum
+	}
+	sum.Add(sum, c.Quantity)
+	return nil
+}
+
+// residuals returns the non-zero sums, rounded to the precision used
+// in the postings of each commodity, sorted by commodity.
+func (b *balancer) residuals() []*Amount {
+	var out []*Amount
+	for commodity, sum := range b.sums {
+		if prec, ok := b.precision[commodity]; ok && isRoundedZero(sum, prec) {
+			continue
+		}
+		if sum.Sign() == 0 {
+			continue
+		}
+		out = append(out, &Amount{Commodity: commodity, Quantity: new(big.Rat).Set(sum)})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Commodity < out[j].Commodity
+	})
+	return out
+}
+
+// isRoundedZero reports whether r rounds to zero with prec decimals.
+func isRoundedZero(r *big.Rat, prec int) bool {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(prec)), nil)
+	scaled := new(big.Rat).Mul(r, new(big.Rat).SetInt(scale))
+	return scaled.Abs(scaled).Cmp(big.NewRat(1, 2)) < 0
+}
+
+// Validate checks that every transaction balances, per commodity,
+// after conversion of the amounts having a price or a lot price.
+// Postings to [bracketed] accounts must balance among themselves, and
+// postings to (parenthesized) accounts are not balanced.  At most one
+// real posting and one [bracketed] posting per transaction can omit
+// their amount, in which case they balance the others of their kind.
+// The failed balance assertions are reported last, see CheckBalances.
+func (j *Journal) Validate() (ErrorList, error) {
+	txs, err := j.Transactions()
+	if err != nil {
+		return nil, err
+	}
+
+	var errs ErrorList
+	for _, tx := range txs {
+		if err := validateXact(tx); err != nil {
+			errs = append(errs, nodeError(j.tree, tx.Node, "%s", err))
+		}
+	}
+
+	balanceErrs, err := j.CheckBalances()
+	return append(errs, balanceErrs...), err
+}
+
+func validateXact(tx *Transaction) error {
+	x := tx.Node
+	groups := map[postingKind]*balancer{
+		realPosting:            newBalancer(tx.journal),
+		balancedVirtualPosting: newBalancer(tx.journal),
+	}
+	for _, p := range x.Postings {
+		amount, err := tx.knownAmount(p)
+		if err != nil {
+			return err
+		}
+
+		kind := kindOf(p)
+		if kind == virtualPosting {
+			if amount == nil {
+				return errors.New("virtual posting " + p.Account + " without an amount")
+			}
+			continue
+		}
+		if err := groups[kind].add(p, amount); err != nil {
+			return err
+		}
+	}
+
+	for _, kind := range []postingKind{realPosting, balancedVirtualPosting} {
+		b := groups[kind]
+		if b.null != nil {
+			continue
+		}
+		if residuals := b.residuals(); len(residuals) != 0 {
+			var msg []string
+			for _, r := range residuals {
+				msg = append(msg, tx.styled(r).String())
+			}
+			return errors.New("transaction does not balance, off by " + strings.Join(msg, ", "))
+		}
+	}
+	return nil
+}
@@ -3,12 +3,12 @@ package journal
 import (
 	"fmt"
 	"math/big"
-	"strings"
 )
 
 type Amount struct {
 	Commodity string
 	Quantity  *big.Rat
+	Style     *Style // How the amount is written, see Journal.Styles. Nil writes it like "10.5 CAD".
 }
 
 func amountToString(v interface{}) string {
@@ -31,7 +31,9 @@ func amountToString(v interface{}) string {
 }
 
 func (a Amount) String() string {
-	q := strings.TrimRight(a.Quantity.FloatString(10), "0")
-	q = strings.TrimRight(q, ".")
-	return q + " " + a.Commodity
+	style := a.Style
+	if style == nil {
+		style = defaultStyle
+	}
+	return style.Format(a.Commodity, a.Quantity)
 }
@@ -0,0 +1,63 @@
+package parse
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// generateJournal returns a journal of n transactions, mixing the
+// constructs commonly found in real files.
+func generateJournal(n int) string {
+	var b strings.Builder
+	b.WriteString("; Generated journal\ncommodity CAD\n  note Canadian dollars\n\n")
+	date := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		d := date.AddDate(0, 0, i/10).Format("2006/01/02")
+		switch i % 4 {
+		case 0:
+			fmt.Fprintf(&b, "%s * (%d) Grocery store  ; :food:\n  Expenses:Food          %d.%02d CAD\n  Assets:Checking\n\n", d, i, i%500, i%100)
+		case 1:
+			fmt.Fprintf(&b, "%s ! Exchange\n  ; Rate: market\n  Assets:USD             10.00 USD @ 1.30 CAD\n  Assets:CAD            -13.00 CAD\n\n", d)
+		case 2:
+			fmt.Fprintf(&b, "%s Paycheck\n  Assets:Checking      1,000.00 CAD = %d.00 CAD\n  Income:Salary\n\n", d, 1000*i)
+		case 3:
+			fmt.Fprintf(&b, "%s Split\n  Expenses:Rent          (600 + 2 * 25 CAD)\n  [Budget:Rent]          -650 CAD\n  Assets:Checking\n\n", d)
+		}
+	}
+	return b.String()
+}
+
+func benchmarkLex(b *testing.B, n int) {
+	input := generateJournal(n)
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l := lex("bench.ledger", input)
+		for {
+			it := l.nextItem()
+			if it.typ == itemEOF || it.typ == itemError {
+				break
+			}
+		}
+	}
+}
+
+func benchmarkParse(b *testing.B, n int) {
+	input := generateJournal(n)
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := New("bench.ledger", input).Parse(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLex1k(b *testing.B)    { benchmarkLex(b, 1000) }
+func BenchmarkLex80k(b *testing.B)   { benchmarkLex(b, 80000) }
+func BenchmarkParse1k(b *testing.B)  { benchmarkParse(b, 1000) }
+func BenchmarkParse80k(b *testing.B) { benchmarkParse(b, 80000) }
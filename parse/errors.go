@@ -0,0 +1,87 @@
+package parse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Mode tells how a Tree is parsed.
+type Mode uint
+
+const (
+	// AllErrors makes Parse skip to the next top-level construct after
+	// an error, rather than stopping at the first one. Parse then
+	// returns an ErrorList, and Root holds what could be parsed.
+	AllErrors Mode = 1 << iota
+)
+
+// Error is a problem found while parsing a Ledger file.
+type Error struct {
+	File     string
+	Line     int // 1-based
+	Column   int // 1-based, in bytes
+	Offset   int // 0-based, in bytes from the start of the file
+	Msg      string
+	Expected []string // kinds of items that were valid at that point, if known
+
+	source string // text of the faulty line, for Snippet
+	width  int    // width of the faulty item, in bytes
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("ledger: %s:%d:%d: %s", e.File, e.Line, e.Column, e.Msg)
+}
+
+// Snippet returns the faulty line, followed by a line underlining the
+// problem with carets, like:
+//
+//	2016/09/09 * * heya!
+//	             ^
+func (e *Error) Snippet() string {
+	if e.Column < 1 || e.Column > len(e.source)+1 {
+		return e.source + "\n"
+	}
+
+	var b strings.Builder
+	b.WriteString(e.source)
+	b.WriteByte('\n')
+	for _, r := range e.source[:e.Column-1] {
+		if r == '\t' {
+			b.WriteByte('\t') // keep the alignment of tabs
+		} else {
+			b.WriteByte(' ')
+		}
+	}
+	width := e.width
+	if rest := len(e.source) - e.Column + 1; width > rest {
+		width = rest
+	}
+	if width < 1 {
+		width = 1
+	}
+	b.WriteString(strings.Repeat("^", width))
+	b.WriteByte('\n')
+	return b.String()
+}
+
+// ErrorList is the list of errors returned by Parse in AllErrors
+// mode. It is itself an error.
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+// Err returns nil when the list is empty, or the list itself otherwise.
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
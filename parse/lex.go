@@ -38,6 +38,7 @@ const (
 	itemNote    // comments for postings
 	itemComment // top-level Journal comments
 	itemDate
+	itemTime // "14:30:00", following the date of a 'P' directive
 	itemLotDate
 	itemLotPrice
 	itemSpace
@@ -72,6 +73,11 @@ const (
 	itemAccountKeyword
 	itemAlias
 	itemPrice
+	itemApply
+	itemEnd
+	itemYear
+	itemDefaultCommodity
+	itemPayeeKeyword
 
 	itemCommodityKeywordsStart
 	itemCommodityDirective
@@ -81,13 +87,26 @@ const (
 	itemCommodityNote
 	itemCommodityAlias
 	itemCommodityKeywordsEnd
+
+	itemAccountKeywordsStart
+	itemAccountNote
+	itemAccountAlias
+	itemAccountPayee
+	itemAccountCheck
+	itemAccountAssert
+	itemAccountEval
+	itemAccountDefault
+	itemAccountKeywordsEnd
+
+	itemPayeeKeywordsStart
+	itemPayeeAlias
+	itemPayeeUUID
+	itemPayeeKeywordsEnd
 	// itemDef
-	// itemYear
 	// itemBucket
 	// itemAssert
 	// itemCheck
 	// itemCommodityConversion
-	// itemDefaultCommodity
 )
 
 // key must contain anything after `itemKeyword` in the preceding list.
@@ -97,6 +116,12 @@ var key = map[string]itemType{
 	"account":   itemAccountKeyword,
 	"P":         itemPrice,
 	"alias":     itemAlias,
+	"apply":     itemApply,
+	"end":       itemEnd,
+	"year":      itemYear,
+	"Y":         itemYear,
+	"D":         itemDefaultCommodity,
+	"payee":     itemPayeeKeyword,
 }
 
 var commodityKey = map[string]itemType{
@@ -107,6 +132,39 @@ var commodityKey = map[string]itemType{
 	"alias":    itemCommodityAlias,
 }
 
+var accountKey = map[string]itemType{
+	"note":    itemAccountNote,
+	"alias":   itemAccountAlias,
+	"payee":   itemAccountPayee,
+	"check":   itemAccountCheck,
+	"assert":  itemAccountAssert,
+	"eval":    itemAccountEval,
+	"default": itemAccountDefault,
+}
+
+var payeeKey = map[string]itemType{
+	"alias": itemPayeeAlias,
+	"uuid":  itemPayeeUUID,
+}
+
+// itemDescriptions are human-readable names of some item types, for
+// error messages.  Others are described by their label.
+var itemDescriptions = map[itemType]string{
+	itemEOF:        "end of file",
+	itemEOL:        "end of line",
+	itemString:     "string",
+	itemDate:       "date",
+	itemTime:       "time",
+	itemRightParen: "')'",
+}
+
+func (typ itemType) description() string {
+	if desc, ok := itemDescriptions[typ]; ok {
+		return desc
+	}
+	return strings.TrimPrefix(label[typ], "item")
+}
+
 var label = map[itemType]string{
 	itemError:              "itemError",
 	itemEOF:                "itemEOF",
@@ -114,6 +172,7 @@ var label = map[itemType]string{
 	itemNote:               "itemNote",
 	itemComment:            "itemComment",
 	itemDate:               "itemDate",
+	itemTime:               "itemTime",
 	itemLotDate:            "itemLotDate",
 	itemLotPrice:           "itemLotPrice",
 	itemSpace:              "itemSpace",
@@ -147,6 +206,23 @@ var label = map[itemType]string{
 	itemCommodityFormat:    "itemCommodityFormat",
 	itemCommodityNote:      "itemCommodityNote",
 	itemCommodityAlias:     "itemCommodityAlias",
+	itemAccountKeyword:     "itemAccountKeyword",
+	itemAlias:              "itemAlias",
+	itemPrice:              "itemPrice",
+	itemApply:              "itemApply",
+	itemEnd:                "itemEnd",
+	itemYear:               "itemYear",
+	itemDefaultCommodity:   "itemDefaultCommodity",
+	itemPayeeKeyword:       "itemPayeeKeyword",
+	itemPayeeAlias:         "itemPayeeAlias",
+	itemPayeeUUID:          "itemPayeeUUID",
+	itemAccountNote:        "itemAccountNote",
+	itemAccountAlias:       "itemAccountAlias",
+	itemAccountPayee:       "itemAccountPayee",
+	itemAccountCheck:       "itemAccountCheck",
+	itemAccountAssert:      "itemAccountAssert",
+	itemAccountEval:        "itemAccountEval",
+	itemAccountDefault:     "itemAccountDefault",
 }
 
 const eof = -1
@@ -156,15 +232,16 @@ type stateFn func(*lexer) stateFn
 
 // lexer holds the state of the scanner.
 type lexer struct {
-	name       string    // the name of the input; used only for error reports
-	input      string    // the string being scanned
-	state      stateFn   // the next lexing function to enter
-	pos        Pos       // current position in the input
-	start      Pos       // start position of this item
-	width      Pos       // width of last rune read from input
-	lastPos    Pos       // position of most recent item returned by nextItem
-	items      chan item // channel of scanned items
-	parenDepth int       // nesting depth of ( ) exprs
+	name       string  // the name of the input; used only for error reports
+	input      string  // the string being scanned
+	state      stateFn // the next lexing function to enter
+	pos        Pos     // current position in the input
+	start      Pos     // start position of this item
+	width      Pos     // width of last rune read from input
+	lastPos    Pos     // position of most recent item returned by nextItem
+	items      []item  // items scanned but not yet returned by nextItem
+	head       int     // index in items of the next item to return
+	parenDepth int     // nesting depth of ( ) exprs
 }
 
 const (
@@ -199,7 +276,7 @@ func (l *lexer) backup() {
 func (l *lexer) emit(t itemType) {
 	it := item{t, l.start, l.input[l.start:l.pos]}
 	//debug(fmt.Sprintf("Piping item: %v", it))
-	l.items <- it
+	l.items = append(l.items, it)
 	l.start = l.pos
 }
 
@@ -228,57 +305,64 @@ func (l *lexer) acceptRun(valid string) {
 	l.backup()
 }
 
-// lineNumber reports which line we're on, based on the position of
-// the previous item returned by nextItem. Doing it this way
-// means we don't have to worry about peek double counting.
-func (l *lexer) lineNumber() int {
-	return 1 + strings.Count(l.input[:l.lastPos], "\n")
-}
-
-// errorf returns an error token and terminates the scan by passing
-// back a nil pointer that will be the next state, terminating l.nextItem.
+// errorf returns an error token and resumes the scan at the next
+// top-level construct, so the parser can report further errors.
 func (l *lexer) errorf(format string, args ...interface{}) stateFn {
-	l.items <- item{itemError, l.start, fmt.Sprintf(format, args...)}
-	return nil
+	l.items = append(l.items, item{itemError, l.start, fmt.Sprintf(format, args...)})
+	return lexSkipToTopLevel
 }
 
-// nextItem returns the next item from the input.
-// Called by the parser, not in the lexing goroutine.
+// nextItem returns the next item from the input, running the state
+// functions until they emit one.  Once the scan is over, it keeps
+// returning itemEOF.
 func (l *lexer) nextItem() item {
-	item := <-l.items
+	for l.head == len(l.items) {
+		// Reuse the queue's storage: a state function seldom emits
+		// more than a handful of items.
+		l.items, l.head = l.items[:0], 0
+		if l.state == nil {
+			return item{itemEOF, l.pos, ""}
+		}
+		l.state = l.state(l)
+	}
+	item := l.items[l.head]
+	l.head++
 	//debug(fmt.Sprintf("Reading item %s", item))
 	l.lastPos = item.pos
 	return item
 }
 
-// drain drains the output so the lexing goroutine will exit.
-// Called by the parser, not in the lexing goroutine.
-func (l *lexer) drain() {
-	for range l.items {
-	}
-}
-
-// lex creates a new scanner for the input string.
+// lex creates a new scanner for the input string.  Scanning happens
+// lazily, as the parser calls nextItem.
 func lex(name, input string) *lexer {
-	l := &lexer{
+	return &lexer{
 		name:  name,
 		input: input,
-		items: make(chan item, 1000),
+		state: lexJournal,
+		items: make([]item, 0, 16),
 	}
-	go l.run()
-	return l
 }
 
-// run runs the state machine for the lexer.
-func (l *lexer) run() {
-	for l.state = lexJournal; l.state != nil; {
-		l.state = l.state(l)
+// Lex State Functions
+
+// lexSkipToTopLevel skips the rest of the line, and the indented lines
+// following it, after an error.
+func lexSkipToTopLevel(l *lexer) stateFn {
+	for {
+		i := strings.IndexByte(l.input[l.pos:], '\n')
+		if i < 0 {
+			l.pos = Pos(len(l.input))
+			break
+		}
+		l.pos += Pos(i + 1)
+		if r := l.peek(); r != ' ' && r != '\t' {
+			break
+		}
 	}
-	close(l.items)
+	l.ignore()
+	return lexJournal
 }
 
-// Lex State Functions
-
 // lexJournal scans the Ledger file for top-level Ledger constructs.
 func lexJournal(l *lexer) stateFn {
 	switch r := l.next(); {
@@ -326,6 +410,20 @@ Loop:
 					return lexPriceDirective
 				case word == "commodity":
 					return lexCommodityDirectives
+				case word == "account":
+					return lexAccountDirectives
+				case word == "alias":
+					return lexAliasDirective
+				case word == "apply":
+					return lexApplyDirective
+				case word == "end":
+					return lexEndDirective
+				case word == "year" || word == "Y":
+					return lexYearDirective
+				case word == "D":
+					return lexDefaultCommodityDirective
+				case word == "payee":
+					return lexPayeeDirectives
 				case key[word] > itemKeyword:
 					l.emit(key[word])
 				default:
@@ -346,7 +444,7 @@ func lexCommodityDirectives(l *lexer) stateFn {
 	l.emit(itemCommodityDirective)
 	l.emitSpaces()
 	if !l.scanCommodity() {
-		return nil
+		return lexSkipToTopLevel
 	}
 	l.emit(itemCommodity)
 	if l.peek() == eof {
@@ -400,16 +498,222 @@ func lexCommodityDirectives(l *lexer) stateFn {
 			return l.errorf("bad character %#U", r)
 		}
 	}
+}
+
+// lexAccountDirectives scans an `account` directive, with its
+// indented sub-directives.
+func lexAccountDirectives(l *lexer) stateFn {
+	var expectIndent bool
+
+	l.emit(itemAccountKeyword)
+	l.emitSpaces()
+	if !l.scanStringToNote() {
+		return l.errorf("missing account name after 'account'")
+	}
+	l.emit(itemAccountName)
+	l.emitTrailingNote()
+
+	for {
+		if expectIndent && !l.emitSpaces() {
+			return lexJournal
+		}
+		expectIndent = false
+
+		switch r := l.next(); {
+		case r == eof:
+			l.backup()
+			return lexJournal
+		case isEndOfLine(r):
+			expectIndent = true
+			l.emit(itemEOL)
+		case isSpace(r):
+			l.emitSpaces()
+		case r == ';':
+			l.emitNote()
+		case isAlphaUnderscore(r):
+			if l.atTerminator() {
+				word := l.input[l.start:l.pos]
+				typ, ok := accountKey[word]
+				if !ok {
+					return l.errorf("unexpected account directive '%s'", word)
+				}
+				l.emit(typ)
+				l.emitSpaces()
+				if typ != itemAccountDefault && !l.emitStringToEOL() {
+					return l.errorf("missing argument to '%s'", word)
+				}
+			}
+		default:
+			return l.errorf("bad character %#U", r)
+		}
+	}
+}
+
+// lexPayeeDirectives scans a `payee` directive, with its indented
+// sub-directives.
+func lexPayeeDirectives(l *lexer) stateFn {
+	var expectIndent bool
+
+	l.emit(itemPayeeKeyword)
+	l.emitSpaces()
+	if !l.scanStringToNote() {
+		return l.errorf("missing payee name after 'payee'")
+	}
+	l.emit(itemString)
+	l.emitTrailingNote()
+
+	for {
+		if expectIndent && !l.emitSpaces() {
+			return lexJournal
+		}
+		expectIndent = false
+
+		switch r := l.next(); {
+		case r == eof:
+			l.backup()
+			return lexJournal
+		case isEndOfLine(r):
+			expectIndent = true
+			l.emit(itemEOL)
+		case isSpace(r):
+			l.emitSpaces()
+		case r == ';':
+			l.emitNote()
+		case isAlphaUnderscore(r):
+			if l.atTerminator() {
+				word := l.input[l.start:l.pos]
+				typ, ok := payeeKey[word]
+				if !ok {
+					return l.errorf("unexpected payee directive '%s'", word)
+				}
+				l.emit(typ)
+				l.emitSpaces()
+				if !l.emitStringToEOL() {
+					return l.errorf("missing argument to '%s'", word)
+				}
+			}
+		default:
+			return l.errorf("bad character %#U", r)
+		}
+	}
+}
+
+// lexAliasDirective scans an `alias` directive, like "alias
+// Checking=Assets:Bank:Checking".
+func lexAliasDirective(l *lexer) stateFn {
+	l.emit(itemAlias)
+	l.emitSpaces()
+	for r := l.peek(); r != '='; r = l.peek() {
+		if isEndOfLine(r) || r == eof {
+			return l.errorf("expected '=' in 'alias' directive")
+		}
+		l.next()
+	}
+	if l.current() == "" {
+		return l.errorf("missing alias before '='")
+	}
+	l.emit(itemAccountName)
+	l.next()
+	l.emit(itemEqual)
+	l.emitSpaces()
+	if !l.scanStringToNote() {
+		return l.errorf("missing account after '=' in 'alias' directive")
+	}
+	l.emit(itemAccountName)
+	l.emitTrailingNote()
+	return lexJournal
+}
+
+// lexApplyDirective scans the opening of an `apply` block, like "apply
+// account Personal".
+func lexApplyDirective(l *lexer) stateFn {
+	l.emit(itemApply)
+	l.emitSpaces()
+	switch word := l.scanWord(); word {
+	case "account":
+		l.emit(itemAccountKeyword)
+		l.emitSpaces()
+		if !l.scanStringToNote() {
+			return l.errorf("missing account name after 'apply account'")
+		}
+		l.emit(itemAccountName)
+	case "year":
+		l.emit(itemYear)
+		l.emitSpaces()
+		if !l.emitYear() {
+			return l.errorf("missing year after 'apply year'")
+		}
+	default:
+		return l.errorf("unsupported directive 'apply %s'", word)
+	}
+	l.emitTrailingNote()
+	return lexJournal
+}
+
+// lexEndDirective scans the end of an `apply` block, "end apply" or
+// "end apply account".
+func lexEndDirective(l *lexer) stateFn {
+	l.emit(itemEnd)
+	l.emitSpaces()
+	if word := l.scanWord(); word != "apply" {
+		return l.errorf("expected 'apply' after 'end', got '%s'", word)
+	}
+	l.emit(itemApply)
+	l.emitSpaces()
+	if !isAlphaUnderscore(l.peek()) {
+		return lexJournal
+	}
+	switch word := l.scanWord(); word {
+	case "account":
+		l.emit(itemAccountKeyword)
+	case "year":
+		l.emit(itemYear)
+	default:
+		return l.errorf("unsupported directive 'end apply %s'", word)
+	}
+	return lexJournal
+}
+
+// lexYearDirective scans a `year` or `Y` directive, like "year 2017",
+// giving the year of the dates written without one.
+func lexYearDirective(l *lexer) stateFn {
+	word := l.current()
+	l.emit(itemYear)
+	l.emitSpaces()
+	if !l.emitYear() {
+		return l.errorf("missing year after '%s'", word)
+	}
 	return lexJournal
+}
 
+// emitYear emits a year of four digits, like "2017", as a string.
+func (l *lexer) emitYear() bool {
+	for i := 0; i < 4; i++ {
+		if !unicode.IsDigit(l.peek()) {
+			return false
+		}
+		l.next()
+	}
+	if !l.atTerminator() {
+		return false
+	}
+	l.emit(itemString)
+	return true
+}
+
+// scanWord scans an alphanumeric word, and returns it.
+func (l *lexer) scanWord() string {
+	for isAlphaNumeric(l.peek()) {
+		l.next()
+	}
+	return l.current()
 }
 
 func lexIncludeDirective(l *lexer) stateFn {
 	l.emit(itemInclude)
 	l.emitSpaces()
 	if !l.emitStringToEOL() {
-		l.errorf("missing filename after 'include'")
-		return nil
+		return l.errorf("missing filename after 'include'")
 	}
 	return lexJournal
 }
@@ -421,20 +725,75 @@ func lexPriceDirective(l *lexer) stateFn {
 	l.emit(itemPrice)
 	l.emitSpaces()
 
-	// TODO: parse individual arguments
-	l.emitStringToEOL()
-	return lexJournal
+	if !l.scanDate() {
+		return lexSkipToTopLevel
+	}
+	l.emit(itemDate)
+	l.emitSpaces()
+
+	if unicode.IsDigit(l.peek()) {
+		if !l.scanTime() {
+			return lexSkipToTopLevel
+		}
+		l.emit(itemTime)
+		l.emitSpaces()
+	}
+
+	if r := l.peek(); !isCommodity(r) {
+		return l.errorf("expected a commodity in 'P' directive, got %#U", r)
+	}
+	if !l.emitCommodity() {
+		return lexSkipToTopLevel
+	}
+	return lexPriceAmount
 }
 
+// lexDefaultCommodityDirective scans a `D` directive, like "D
+// $1,000.00".
+func lexDefaultCommodityDirective(l *lexer) stateFn {
+	l.emit(itemDefaultCommodity)
+	l.emitSpaces()
+	return lexPriceAmount
+}
+
+// lexPriceAmount scans the price of a 'P' directive, like "50.00 CAD"
+// or "$50", or the amount of a 'D' directive, and an optional note.
+func lexPriceAmount(l *lexer) stateFn {
+	switch r := l.peek(); {
+	case r == '-':
+		l.next()
+		l.emit(itemNeg)
+	case unicode.IsDigit(r) || r == '.':
+		if !l.emitQuantity() {
+			return lexSkipToTopLevel
+		}
+	case isSpace(r):
+		l.emitSpaces()
+	case r == ';':
+		l.emitNote()
+	case isEndOfLine(r) || r == eof:
+		return lexJournal
+	case isCommodity(r):
+		if !l.emitCommodity() {
+			return lexSkipToTopLevel
+		}
+	default:
+		return l.errorf("unexpected character in price: %#U", r)
+	}
+	return lexPriceAmount
+}
+
+// lexPeriodicXact scans the period expression following the '~' of a
+// periodic transaction, emitted as a string, and an optional note.
 func lexPeriodicXact(l *lexer) stateFn {
-	// TODO: support those things...
 	l.emitSpaces()
 	l.emitStringNote()
 	return lexPostings
 }
 
+// lexAutomatedXact scans the predicate following the '=' of an
+// automated transaction, emitted as a string, and an optional note.
 func lexAutomatedXact(l *lexer) stateFn {
-	// TODO: support those things...
 	l.emitSpaces()
 	l.emitStringNote()
 	return lexPostings
@@ -442,24 +801,22 @@ func lexAutomatedXact(l *lexer) stateFn {
 
 func lexPlainXact(l *lexer) stateFn {
 	if !l.scanDate() {
-		return nil
+		return lexSkipToTopLevel
 	}
 	l.emit(itemDate)
 	l.emitSpaces()
 
 	switch r := l.peek(); {
 	case r == eof:
-		l.errorf("unexpected end-of-file")
-		return nil
+		return l.errorf("unexpected end-of-file")
 	case isEndOfLine(r):
-		l.errorf("unexpected end-of-line")
-		return nil
+		return l.errorf("unexpected end-of-line")
 	case r == '=':
 		l.next()
 		l.emit(itemEqual)
 		l.emitSpaces()
 		if !l.scanDate() {
-			return nil
+			return lexSkipToTopLevel
 		}
 		l.emit(itemDate)
 		l.emitSpaces()
@@ -482,11 +839,9 @@ func lexPlainXactDescription(l *lexer) stateFn {
 		l.emit(itemRightParen)
 		l.emitSpaces()
 	case isEndOfLine(r):
-		l.errorf("unexpected end-of-line")
-		return nil
+		return l.errorf("unexpected end-of-line")
 	case r == eof:
-		l.errorf("unexpected end-of-file")
-		return nil
+		return l.errorf("unexpected end-of-file")
 	default:
 		l.backup()
 		l.emitStringNote()
@@ -538,6 +893,24 @@ Loop:
 	return true
 }
 
+// scanStringToNote scans up to a note or the end of the line, leaving
+// out the spaces before the note.
+func (l *lexer) scanStringToNote() bool {
+	for r := l.peek(); r != ';' && !isEndOfLine(r) && r != eof; r = l.peek() {
+		l.next()
+	}
+	l.pos = l.start + Pos(len(strings.TrimRight(l.current(), spaceChars)))
+	return l.current() != ""
+}
+
+// emitTrailingNote emits the spaces and the note ending a line, if any.
+func (l *lexer) emitTrailingNote() {
+	l.emitSpaces()
+	if l.peek() == ';' {
+		l.emitNote()
+	}
+}
+
 func (l *lexer) emitNote() {
 	for {
 		switch r := l.next(); {
@@ -667,7 +1040,7 @@ Loop:
 			l.emitNote()
 		case unicode.IsLetter(r):
 			if !l.scanAccountName() {
-				return nil
+				return lexSkipToTopLevel
 			}
 			l.emitSpaces()
 			return lexPostingValues
@@ -684,7 +1057,7 @@ func lexPostingValues(l *lexer) stateFn {
 	case r == '(':
 		l.next()
 		if !l.emitValueExpr() {
-			return nil
+			return lexSkipToTopLevel
 		}
 	case r == '=':
 		l.next()
@@ -694,34 +1067,29 @@ func lexPostingValues(l *lexer) stateFn {
 		l.emit(itemNeg)
 	case unicode.IsDigit(r) || r == '.':
 		if !l.emitQuantity() {
-			return nil
+			return lexSkipToTopLevel
 		}
 	case r == '@':
 		if !l.emitPrices() {
-			return nil
+			return lexSkipToTopLevel
 		}
 	case r == '[':
 		l.next()
 		if !l.scanDate() {
-			return nil
+			return lexSkipToTopLevel
 		}
 		if !l.accept("]") {
-			l.errorf("expected matching ']' for lot date, got %#U", l.next())
-			return nil
+			return l.errorf("expected matching ']' for lot date, got %#U", l.next())
 		}
 		l.emit(itemLotDate)
 	case r == '{':
-		// TODO: this should support a full "amount" to be specified,
-		// so "- 123 USD", not only a quantity.
-		// We should split this function `lexPostingValues` into `lexAmount`
-		// which would only handle the amounts, so we could feed it more than once.
+		// The amount within the braces, like "{50.00 USD}", is
+		// split up by the parser.
 		l.next()
-		if !l.scanQuantity() {
-			return nil
-		}
-		if !l.accept("}") {
-			l.errorf("expected matching '}' for lot price, got %#U, don't specify commodity here", l.next())
-			return nil
+		for r := l.next(); r != '}'; r = l.next() {
+			if isEndOfLine(r) || r == eof {
+				return l.errorf("expected matching '}' for lot price, got %#U", r)
+			}
 		}
 		l.emit(itemLotPrice)
 		// TODO: currently no support for '(' lot_note ')'..
@@ -735,12 +1103,11 @@ func lexPostingValues(l *lexer) stateFn {
 		l.emitNote()
 	case isCommodity(r):
 		if !l.scanCommodity() {
-			return nil
+			return lexSkipToTopLevel
 		}
 		l.emit(itemCommodity)
 	default:
-		l.errorf("unexpected character in posting values: %#U", r)
-		return nil
+		return l.errorf("unexpected character in posting values: %#U", r)
 	}
 	return lexPostingValues
 	/*
@@ -847,6 +1214,9 @@ func (l *lexer) emitPrices() bool {
 			}
 		case isSpace(r):
 			l.emitSpaces()
+		case r == '{' || r == '[':
+			// Lot annotations, scanned by lexPostingValues.
+			return true
 		case isCommodity(r):
 			if !l.scanCommodity() {
 				return false
@@ -858,10 +1228,12 @@ func (l *lexer) emitPrices() bool {
 	}
 }
 
-// scanDate scans dates in whatever format.
+// scanDate scans dates in whatever format, with a year or without one,
+// like "2017/06/15" or "06/15".
 func (l *lexer) scanDate() bool {
-	const dateError = "date format error, expects YYYY-MM-DD with '/', '-' or '.' as separators, received character %#U"
+	const dateError = "date format error, expects YYYY-MM-DD or MM-DD with '/', '-' or '.' as separators, received character %#U"
 	fields := []int{4, 2, 2}
+	short := false // no year, the first field being a month.
 	for {
 		fieldExpected := fields[0]
 
@@ -874,13 +1246,16 @@ func (l *lexer) scanDate() bool {
 				return false
 			}
 		case r == '.' || r == '-' || r == '/':
-			if len(fields) == 0 {
+			if len(fields) == 0 || short {
 				l.errorf(dateError, r)
 				return false
 			}
+			if len(fields) == 3 && fieldExpected >= 2 {
+				short = true
+			}
 			fields = fields[1:]
 		default:
-			if len(fields) != 1 {
+			if len(fields) != 1 && !(short && len(fields) == 2 && fieldExpected < 2) {
 				l.errorf(dateError, r)
 				return false
 			}
@@ -890,6 +1265,25 @@ func (l *lexer) scanDate() bool {
 	}
 }
 
+// scanTime scans a time of day, like "14:30" or "14:30:00".
+func (l *lexer) scanTime() bool {
+	colons := 0
+	for {
+		switch r := l.next(); {
+		case unicode.IsDigit(r):
+		case r == ':':
+			colons++
+		default:
+			l.backup()
+			if colons == 0 || colons > 2 || !l.atTerminator() {
+				l.errorf("time format error, expects HH:MM or HH:MM:SS, received %q", l.current())
+				return false
+			}
+			return true
+		}
+	}
+}
+
 // emitValueExpr reads until last unbound ')'
 func (l *lexer) emitValueExpr() bool {
 	var parenCount int
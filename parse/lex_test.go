@@ -141,7 +141,29 @@ var lexTests = []lexTest{
 	{"price directive", `P 2017/06/15 USD 50.00 CAD`, []item{
 		{itemPrice, 0, "P"},
 		{itemSpace, 0, " "},
-		{itemString, 0, "2017/06/15 USD 50.00 CAD"},
+		{itemDate, 0, "2017/06/15"},
+		{itemSpace, 0, " "},
+		{itemCommodity, 0, "USD"},
+		{itemSpace, 0, " "},
+		{itemQuantity, 0, "50.00"},
+		{itemSpace, 0, " "},
+		{itemCommodity, 0, "CAD"},
+		tEOF,
+	}},
+	{"price directive with time", "P 2017/06/15 14:30 AAPL $142.50 ; close\n", []item{
+		{itemPrice, 0, "P"},
+		{itemSpace, 0, " "},
+		{itemDate, 0, "2017/06/15"},
+		{itemSpace, 0, " "},
+		{itemTime, 0, "14:30"},
+		{itemSpace, 0, " "},
+		{itemCommodity, 0, "AAPL"},
+		{itemSpace, 0, " "},
+		{itemCommodity, 0, "$"},
+		{itemQuantity, 0, "142.50"},
+		{itemSpace, 0, " "},
+		{itemNote, 0, "; close"},
+		tEOL,
 		tEOF,
 	}},
 	{"commodity directive simple", `commodity $`, []item{
@@ -181,6 +203,70 @@ var lexTests = []lexTest{
 		tEOF,
 	}},
 
+	{"account directive with subdirectives", "account Assets:Bank Checking\n  note Main account\n  payee ^Hydro\n  default\n\n", []item{
+		{itemAccountKeyword, 0, "account"},
+		{itemSpace, 0, " "},
+		{itemAccountName, 0, "Assets:Bank Checking"},
+		tEOL,
+		{itemSpace, 0, "  "},
+		{itemAccountNote, 0, "note"},
+		{itemSpace, 0, " "},
+		{itemString, 0, "Main account"},
+		tEOL,
+		{itemSpace, 0, "  "},
+		{itemAccountPayee, 0, "payee"},
+		{itemSpace, 0, " "},
+		{itemString, 0, "^Hydro"},
+		tEOL,
+		{itemSpace, 0, "  "},
+		{itemAccountDefault, 0, "default"},
+		tEOL,
+		tEOL,
+		tEOF,
+	}},
+	{"account directive with notes", "account Assets:Bank  ; main\n  ; closed\n", []item{
+		{itemAccountKeyword, 0, "account"},
+		{itemSpace, 0, " "},
+		{itemAccountName, 0, "Assets:Bank"},
+		{itemSpace, 0, "  "},
+		{itemNote, 0, "; main"},
+		tEOL,
+		{itemSpace, 0, "  "},
+		{itemNote, 0, "; closed"},
+		tEOL,
+		tEOF,
+	}},
+	{"payee directive with subdirectives", "payee Amazon\n  alias ^AMZN Mktp\n  uuid 2a2e21d4\n\n", []item{
+		{itemPayeeKeyword, 0, "payee"},
+		{itemSpace, 0, " "},
+		{itemString, 0, "Amazon"},
+		tEOL,
+		{itemSpace, 0, "  "},
+		{itemPayeeAlias, 0, "alias"},
+		{itemSpace, 0, " "},
+		{itemString, 0, "^AMZN Mktp"},
+		tEOL,
+		{itemSpace, 0, "  "},
+		{itemPayeeUUID, 0, "uuid"},
+		{itemSpace, 0, " "},
+		{itemString, 0, "2a2e21d4"},
+		tEOL,
+		tEOL,
+		tEOF,
+	}},
+	{"year and short date", "Y 2017\n03/15=3/16 Payee", []item{
+		{itemYear, 0, "Y"},
+		{itemSpace, 0, " "},
+		{itemString, 0, "2017"},
+		tEOL,
+		{itemDate, 0, "03/15"},
+		{itemEqual, 0, "="},
+		{itemDate, 0, "3/16"},
+		{itemSpace, 0, " "},
+		{itemString, 0, "Payee"},
+		tEOF,
+	}},
+
 	// errors
 
 	{"plain xact eof", "2016/09/09", []item{
@@ -192,13 +278,21 @@ var lexTests = []lexTest{
 		{itemError, 0, "unexpected end-of-line"},
 	}},
 	{"erroneous date non-digit", "2016/09eee\n", []item{
-		{itemError, 0, "date format error, expects YYYY-MM-DD with '/', '-' or '.' as separators, received character U+0065 'e'"},
+		{itemError, 0, "date format error, expects YYYY-MM-DD or MM-DD with '/', '-' or '.' as separators, received character U+0065 'e'"},
 	}},
 	{"erroneous date", "2016/099/08 Payee", []item{
-		{itemError, 0, "date format error, expects YYYY-MM-DD with '/', '-' or '.' as separators, received character U+0039 '9'"},
+		{itemError, 0, "date format error, expects YYYY-MM-DD or MM-DD with '/', '-' or '.' as separators, received character U+0039 '9'"},
+	}},
+	{"erroneous date without a day", "03/", []item{
+		{itemError, 0, "date format error, expects YYYY-MM-DD or MM-DD with '/', '-' or '.' as separators, received character U+FFFFFFFFFFFFFFFF"},
+	}},
+	{"erroneous year", "year 17\n", []item{
+		{itemYear, 0, "year"},
+		{itemSpace, 0, " "},
+		{itemError, 0, "missing year after 'year'"},
 	}},
 	{"erroneous short date", "2016/09", []item{
-		{itemError, 0, "date format error, expects YYYY-MM-DD with '/', '-' or '.' as separators, received character U+FFFFFFFFFFFFFFFF"},
+		{itemError, 0, "date format error, expects YYYY-MM-DD or MM-DD with '/', '-' or '.' as separators, received character U+FFFFFFFFFFFFFFFF"},
 	}},
 	{"commodity unknown", "commodity A\n  bob", []item{
 		{itemCommodityDirective, 0, "commodity"},
@@ -208,6 +302,26 @@ var lexTests = []lexTest{
 		{itemSpace, 0, "  "},
 		{itemError, 0, "unexpected commodity directive 'bob'"},
 	}},
+	{"account unknown", "account A\n  bob", []item{
+		{itemAccountKeyword, 0, "account"},
+		{itemSpace, 0, " "},
+		{itemAccountName, 0, "A"},
+		tEOL,
+		{itemSpace, 0, "  "},
+		{itemError, 0, "unexpected account directive 'bob'"},
+	}},
+	{"payee unknown", "payee A\n  bob", []item{
+		{itemPayeeKeyword, 0, "payee"},
+		{itemSpace, 0, " "},
+		{itemString, 0, "A"},
+		tEOL,
+		{itemSpace, 0, "  "},
+		{itemError, 0, "unexpected payee directive 'bob'"},
+	}},
+	{"payee without a name", "payee\n", []item{
+		{itemPayeeKeyword, 0, "payee"},
+		{itemError, 0, "missing payee name after 'payee'"},
+	}},
 }
 
 func TestLex(t *testing.T) {
@@ -3,8 +3,11 @@ package parse
 import (
 	"bytes"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/abourget/ledger/period"
 )
 
 var textFormat = "%s" // Changed to "%q" in tests for better error messages.
@@ -20,6 +23,7 @@ type Node interface {
 	// CopyXxx methods that return *XxxNode.
 	//Copy() Node
 	Position() Pos // byte position of start of node in full original input string
+	Span() Span    // byte range of the node in full original input string
 	// tree returns the containing *Tree.
 	// It is unexported so all implementations of Node are in this package.
 	tree() *Tree
@@ -36,6 +40,13 @@ func (p Pos) Position() Pos {
 	return p
 }
 
+// Span is the range of bytes [Start, End) of a node in the original
+// input text.  It excludes the spaces and end of line following the
+// node, so replacing t.Text()[Start:End] replaces exactly the node.
+type Span struct {
+	Start, End Pos
+}
+
 // Type returns itself and provides an easy default implementation
 // for embedding in a Node. Embedded in all non-trivial Nodes.
 func (t NodeType) Type() NodeType {
@@ -64,18 +75,38 @@ const (
 	NodeAmount
 	NodeDirective
 	NodeCommodity
+	NodeAutoXact
+	NodePeriodicXact
+	NodeAccount
+	NodePrice
+	NodeAlias
+	NodeApply
+	NodeEndApply
+	NodeYear
+	NodeDefaultCommodity
+	NodePayee
 )
 
 var nodeLabel = map[NodeType]string{
-	NodeJournal:   "NodeJournal",
-	NodeList:      "NodeList",
-	NodeXact:      "NodeXact",
-	NodePosting:   "NodePosting",
-	NodeComment:   "NodeComment",
-	NodeSpace:     "NodeSpace",
-	NodeAmount:    "NodeAmount",
-	NodeDirective: "NodeDirective",
-	NodeCommodity: "NodeCommodity",
+	NodeJournal:          "NodeJournal",
+	NodeList:             "NodeList",
+	NodeXact:             "NodeXact",
+	NodePosting:          "NodePosting",
+	NodeComment:          "NodeComment",
+	NodeSpace:            "NodeSpace",
+	NodeAmount:           "NodeAmount",
+	NodeDirective:        "NodeDirective",
+	NodeCommodity:        "NodeCommodity",
+	NodeAutoXact:         "NodeAutoXact",
+	NodePeriodicXact:     "NodePeriodicXact",
+	NodeAccount:          "NodeAccount",
+	NodePrice:            "NodePrice",
+	NodeAlias:            "NodeAlias",
+	NodeApply:            "NodeApply",
+	NodeEndApply:         "NodeEndApply",
+	NodeYear:             "NodeYear",
+	NodeDefaultCommodity: "NodeDefaultCommodity",
+	NodePayee:            "NodePayee",
 }
 
 /** ListNode **/
@@ -84,7 +115,8 @@ var nodeLabel = map[NodeType]string{
 type ListNode struct {
 	NodeType
 	Pos
-	tr *Tree
+	End Pos // byte position just after the node, see Span
+	tr  *Tree
 
 	Nodes []Node // The top-level element nodes in lexical order.
 }
@@ -101,6 +133,10 @@ func (l *ListNode) tree() *Tree {
 	return l.tr
 }
 
+func (l *ListNode) Span() Span {
+	return Span{l.Pos, l.End}
+}
+
 func (l *ListNode) String() string {
 	b := new(bytes.Buffer)
 	for _, n := range l.Nodes {
@@ -129,51 +165,58 @@ func (l *ListNode) String() string {
 type SpaceNode struct {
 	NodeType
 	Pos
-	tr *Tree
+	End Pos // byte position just after the node, see Span
+	tr  *Tree
 
 	Space string
 }
 
 func (t *Tree) newSpace(p Pos, spaces string) *SpaceNode {
-	return &SpaceNode{NodeType: NodeSpace, Pos: p, tr: t, Space: spaces}
+	return &SpaceNode{NodeType: NodeSpace, Pos: p, End: p + Pos(len(spaces)), tr: t, Space: spaces}
 }
 
 func (n *SpaceNode) String() string { return n.Space }
 func (n *SpaceNode) tree() *Tree    { return n.tr }
+func (n *SpaceNode) Span() Span     { return Span{n.Pos, n.End} }
 
 /** CommentNode **/
 
 type CommentNode struct {
 	NodeType
 	Pos
-	tr *Tree
+	End Pos // byte position just after the node, see Span
+	tr  *Tree
 
 	Comment string
 }
 
 func (t *Tree) newComment(i item) *CommentNode {
-	return &CommentNode{NodeType: NodeComment, Pos: i.pos, tr: t, Comment: i.val}
+	return &CommentNode{NodeType: NodeComment, Pos: i.pos, End: i.pos + Pos(len(i.val)), tr: t, Comment: i.val}
 }
 
 func (n *CommentNode) String() string { return n.Comment }
 func (n *CommentNode) tree() *Tree    { return n.tr }
+func (n *CommentNode) Span() Span     { return Span{n.Pos, n.End} }
 
 /** XactNode - Ledger Transactions **/
 
 type XactNode struct {
 	NodeType
 	Pos
-	tr *Tree
-
-	Date          time.Time
-	EffectiveDate time.Time
-	Description   string
-	Code          string
-	IsPending     bool
-	IsCleared     bool
-	NotePreSpace  string
-	Note          string
-	Postings      []*PostingNode
+	End Pos // byte position just after the node, see Span
+	tr  *Tree
+
+	Date               time.Time
+	EffectiveDate      time.Time
+	ShortDate          bool // Date written without a year, like "03/15", see YearNode.
+	ShortEffectiveDate bool
+	Description        string
+	Code               string
+	IsPending          bool
+	IsCleared          bool
+	NotePreSpace       string
+	Note               string
+	Postings           []*PostingNode
 }
 
 func (t *Tree) newXact(pos Pos) *XactNode {
@@ -198,6 +241,7 @@ func (n *XactNode) String() string {
 }
 
 func (n *XactNode) tree() *Tree { return n.tr }
+func (n *XactNode) Span() Span  { return Span{n.Pos, n.End} }
 
 // func (n *XactNode) Copy() Node {
 // 	return &XactNode{tr: n.tr, NodeType: n.NodeType, Pos: n.Pos, Date: n.Date, Description: n.Description, IsPending: n.IsPending, IsCleared: n.IsCleared}
@@ -209,12 +253,123 @@ func (n *XactNode) newPosting(pos Pos) *PostingNode {
 	return p
 }
 
+func (n *XactNode) appendNote(note string) {
+	if n.Note == "" {
+		n.NotePreSpace = "\n" // the note starts on the line after the description
+	}
+	n.Note = appendComment(n.Note, note)
+}
+
+// postingsHolder is implemented by the nodes that own a list of
+// postings, so they can share the postings parser.
+type postingsHolder interface {
+	newPosting(pos Pos) *PostingNode
+	appendNote(note string)
+}
+
+/** AutoXactNode - Automated transactions **/
+
+// AutoXactNode is an automated transaction, introduced by '=' and a
+// predicate. Its postings are added to every transaction with a
+// posting matching the predicate.  An amount without commodity, like
+// `0.1` or `(0.1)`, is a multiplier of the matched posting's amount.
+type AutoXactNode struct {
+	NodeType
+	Pos
+	End Pos // byte position just after the node, see Span
+	tr  *Tree
+
+	Predicate    string // Raw predicate expression, like "/^Income:Salary/" or "expr account =~ /Food/"
+	NotePreSpace string // Spaces before a note on the same line, or "\n" for a note on the next line.
+	Note         string
+	Postings     []*PostingNode
+}
+
+func (t *Tree) newAutoXact(pos Pos) *AutoXactNode {
+	n := &AutoXactNode{tr: t, NodeType: NodeAutoXact, Pos: pos}
+	t.Root.add(n)
+	return n
+}
+
+func (n *AutoXactNode) String() string {
+	msg := "= " + n.Predicate
+	if n.Note != "" {
+		msg += n.NotePreSpace + n.Note
+	}
+	return fmt.Sprintf(textFormat, msg)
+}
+
+func (n *AutoXactNode) tree() *Tree { return n.tr }
+func (n *AutoXactNode) Span() Span  { return Span{n.Pos, n.End} }
+
+func (n *AutoXactNode) newPosting(pos Pos) *PostingNode {
+	p := &PostingNode{tr: n.tr, NodeType: NodePosting, Pos: pos}
+	n.Postings = append(n.Postings, p)
+	return p
+}
+
+func (n *AutoXactNode) appendNote(note string) {
+	if n.Note == "" {
+		n.NotePreSpace = "\n"
+	}
+	n.Note = appendComment(n.Note, note)
+}
+
+/** PeriodicXactNode - Periodic transactions **/
+
+// PeriodicXactNode is a periodic transaction, introduced by '~' and a
+// period expression like `Monthly` or `every 2 weeks from 2024/01/01`.
+// It is most commonly used to define budgets.
+type PeriodicXactNode struct {
+	NodeType
+	Pos
+	End Pos // byte position just after the node, see Span
+	tr  *Tree
+
+	PeriodExpr   string         // Raw period expression, as written in the file.
+	Period       *period.Period // Parsed version of PeriodExpr, with relative dates resolved at parse time.
+	NotePreSpace string         // Spaces before a note on the same line, or "\n" for a note on the next line.
+	Note         string
+	Postings     []*PostingNode
+}
+
+func (t *Tree) newPeriodicXact(pos Pos) *PeriodicXactNode {
+	n := &PeriodicXactNode{tr: t, NodeType: NodePeriodicXact, Pos: pos}
+	t.Root.add(n)
+	return n
+}
+
+func (n *PeriodicXactNode) String() string {
+	msg := "~ " + n.PeriodExpr
+	if n.Note != "" {
+		msg += n.NotePreSpace + n.Note
+	}
+	return fmt.Sprintf(textFormat, msg)
+}
+
+func (n *PeriodicXactNode) tree() *Tree { return n.tr }
+func (n *PeriodicXactNode) Span() Span  { return Span{n.Pos, n.End} }
+
+func (n *PeriodicXactNode) newPosting(pos Pos) *PostingNode {
+	p := &PostingNode{tr: n.tr, NodeType: NodePosting, Pos: pos}
+	n.Postings = append(n.Postings, p)
+	return p
+}
+
+func (n *PeriodicXactNode) appendNote(note string) {
+	if n.Note == "" {
+		n.NotePreSpace = "\n"
+	}
+	n.Note = appendComment(n.Note, note)
+}
+
 /** PostingNode - Postings to transactions **/
 
 type PostingNode struct {
 	NodeType
 	Pos
-	tr *Tree
+	End Pos // byte position just after the node, see Span
+	tr  *Tree
 
 	IsPending         bool
 	IsCleared         bool
@@ -242,13 +397,15 @@ func (n *PostingNode) String() string {
 }
 
 func (n *PostingNode) tree() *Tree { return n.tr }
+func (n *PostingNode) Span() Span  { return Span{n.Pos, n.End} }
 
 /** PostingNode - Postings to transactions **/
 
 type AmountNode struct {
 	NodeType
 	Pos
-	tr *Tree
+	End Pos // byte position just after the node, see Span
+	tr  *Tree
 
 	Raw       string // Raw representation of the amount, like "- CAD  100.20", with spacing and all. Will be used for printing unless empty, in which case the string will be reconstructed based on the other values herein.
 	Quantity  string
@@ -282,11 +439,17 @@ func (n *AmountNode) String() string {
 }
 
 func (n *AmountNode) tree() *Tree { return n.tr }
+func (n *AmountNode) Span() Span  { return Span{n.Pos, n.End} }
 
+// next consumes an item of the amount. The span of the amount goes from
+// its first to its last item, spaces excluded.
 func (n *AmountNode) next(t *Tree) item {
 	it := t.next()
-	if n.Pos == 0 {
-		n.Pos = it.pos
+	if it.typ != itemSpace {
+		if n.End == 0 {
+			n.Pos = it.pos
+		}
+		n.End = it.pos + Pos(len(it.val))
 	}
 	n.Raw += it.val
 	return it
@@ -301,7 +464,8 @@ func (n *AmountNode) space(t *Tree) {
 type DirectiveNode struct {
 	NodeType
 	Pos
-	tr *Tree
+	End Pos // byte position just after the node, see Span
+	tr  *Tree
 
 	Raw       string
 	Directive string
@@ -322,11 +486,13 @@ func (n *DirectiveNode) String() string {
 	return str
 }
 func (n *DirectiveNode) tree() *Tree { return n.tr }
+func (n *DirectiveNode) Span() Span  { return Span{n.Pos, n.End} }
 
 type CommodityNode struct {
 	NodeType
 	Pos
-	tr *Tree
+	End Pos // byte position just after the node, see Span
+	tr  *Tree
 
 	Commodity string
 	Note      string
@@ -343,3 +509,218 @@ func (t *Tree) newCommodity(p Pos) *CommodityNode {
 }
 
 func (n *CommodityNode) tree() *Tree { return n.tr }
+func (n *CommodityNode) Span() Span  { return Span{n.Pos, n.End} }
+
+// PriceNode is a `P` directive, giving the price of a commodity at a
+// point in time, like "P 2017/06/15 12:00:00 USD 1.30 CAD".
+type PriceNode struct {
+	NodeType
+	Pos
+	End Pos // byte position just after the node, see Span
+	tr  *Tree
+
+	Date      time.Time // Including the time of day, if any.
+	ShortDate bool      // Date written without a year, see YearNode.
+	Time      string    // The time of day as written, like "12:00:00", or empty.
+	Commodity string
+	Price     *AmountNode
+	Note      string
+}
+
+func (t *Tree) newPrice(p Pos) *PriceNode {
+	d := &PriceNode{NodeType: NodePrice, Pos: p, tr: t}
+	t.Root.add(d)
+	return d
+}
+
+func (n *PriceNode) String() string {
+	date := n.Date.Format("2006/01/02")
+	if n.Time != "" {
+		date += " " + n.Time
+	}
+	return fmt.Sprintf("P %s %s %s", date, n.Commodity, strings.TrimSpace(n.Price.String()))
+}
+func (n *PriceNode) tree() *Tree { return n.tr }
+func (n *PriceNode) Span() Span  { return Span{n.Pos, n.End} }
+
+// AliasNode is an `alias` directive, giving a short name to an
+// account, like "alias Checking=Assets:Bank:Checking".
+type AliasNode struct {
+	NodeType
+	Pos
+	End Pos // byte position just after the node, see Span
+	tr  *Tree
+
+	Alias   string
+	Account string
+	Note    string
+}
+
+func (t *Tree) newAlias(p Pos) *AliasNode {
+	d := &AliasNode{NodeType: NodeAlias, Pos: p, tr: t}
+	t.Root.add(d)
+	return d
+}
+
+func (n *AliasNode) String() string { return "alias " + n.Alias + "=" + n.Account }
+func (n *AliasNode) tree() *Tree    { return n.tr }
+func (n *AliasNode) Span() Span     { return Span{n.Pos, n.End} }
+
+// ApplyNode opens an `apply` block, like "apply account Personal",
+// which applies to the entries up to the matching EndApplyNode.
+type ApplyNode struct {
+	NodeType
+	Pos
+	End Pos // byte position just after the node, see Span
+	tr  *Tree
+
+	Directive string // What is applied, like "account".
+	Args      string // Like "Personal".
+	Note      string
+}
+
+func (t *Tree) newApply(p Pos) *ApplyNode {
+	d := &ApplyNode{NodeType: NodeApply, Pos: p, tr: t}
+	t.Root.add(d)
+	return d
+}
+
+func (n *ApplyNode) String() string { return "apply " + n.Directive + " " + n.Args }
+func (n *ApplyNode) tree() *Tree    { return n.tr }
+func (n *ApplyNode) Span() Span     { return Span{n.Pos, n.End} }
+
+// EndApplyNode closes the latest `apply` block, written "end apply" or
+// with the directive applied, like "end apply account".
+type EndApplyNode struct {
+	NodeType
+	Pos
+	End Pos // byte position just after the node, see Span
+	tr  *Tree
+
+	Directive string // Like "account", or empty.
+}
+
+func (t *Tree) newEndApply(p Pos) *EndApplyNode {
+	d := &EndApplyNode{NodeType: NodeEndApply, Pos: p, tr: t}
+	t.Root.add(d)
+	return d
+}
+
+func (n *EndApplyNode) String() string {
+	if n.Directive == "" {
+		return "end apply"
+	}
+	return "end apply " + n.Directive
+}
+func (n *EndApplyNode) tree() *Tree { return n.tr }
+func (n *EndApplyNode) Span() Span  { return Span{n.Pos, n.End} }
+
+// YearNode is a `year` or `Y` directive, like "year 2017", giving the
+// year of the dates written without one, like "03/15", that follow.
+type YearNode struct {
+	NodeType
+	Pos
+	End Pos // byte position just after the node, see Span
+	tr  *Tree
+
+	Directive string // "year" or "Y", as written.
+	Year      int
+}
+
+func (t *Tree) newYear(p Pos) *YearNode {
+	d := &YearNode{NodeType: NodeYear, Pos: p, tr: t}
+	t.Root.add(d)
+	return d
+}
+
+func (n *YearNode) String() string { return n.Directive + " " + strconv.Itoa(n.Year) }
+func (n *YearNode) tree() *Tree    { return n.tr }
+func (n *YearNode) Span() Span     { return Span{n.Pos, n.End} }
+
+// DefaultCommodityNode is a `D` directive, like "D $1,000.00", giving
+// the default commodity and the way its amounts are written.
+type DefaultCommodityNode struct {
+	NodeType
+	Pos
+	End Pos // byte position just after the node, see Span
+	tr  *Tree
+
+	Amount *AmountNode
+	Note   string
+}
+
+func (t *Tree) newDefaultCommodity(p Pos) *DefaultCommodityNode {
+	d := &DefaultCommodityNode{NodeType: NodeDefaultCommodity, Pos: p, tr: t}
+	t.Root.add(d)
+	return d
+}
+
+func (n *DefaultCommodityNode) String() string {
+	return "D " + strings.TrimSpace(n.Amount.String())
+}
+func (n *DefaultCommodityNode) tree() *Tree { return n.tr }
+func (n *DefaultCommodityNode) Span() Span  { return Span{n.Pos, n.End} }
+
+// AccountNode is an `account` directive, declaring an account and its
+// properties.
+type AccountNode struct {
+	NodeType
+	Pos
+	End Pos // byte position just after the node, see Span
+	tr  *Tree
+
+	Account string
+	Note    string // Comment on the line of the account name, like "; main bank".
+	Notes   []string
+	Aliases []string // Short names of the account, like `alias` directives.
+	Payees  []string // Regexps of payees for which postings default to this account.
+	Checks  []string // Value expressions that should hold for each posting, warning otherwise.
+	Asserts []string // Value expressions that must hold for each posting.
+	Evals   []string // Value expressions evaluated for each posting.
+	Default bool     // Account used for postings with no account, like bank statements.
+
+	SubDirectives []SubDirective // The lines of the block, in the order written.
+}
+
+func (t *Tree) newAccount(p Pos) *AccountNode {
+	d := &AccountNode{NodeType: NodeAccount, Pos: p, tr: t}
+	t.Root.add(d)
+	return d
+}
+
+func (n *AccountNode) String() string { return "account " + n.Account }
+func (n *AccountNode) tree() *Tree    { return n.tr }
+func (n *AccountNode) Span() Span     { return Span{n.Pos, n.End} }
+
+// SubDirective is a line of the block of an `account` or `payee`
+// directive, like "alias Checking", or a comment line.
+type SubDirective struct {
+	Keyword string // Like "alias", or empty for a comment line.
+	Value   string // Argument of the sub-directive, or the comment, like "; closed".
+}
+
+// PayeeNode is a `payee` directive, declaring a payee and the
+// descriptions of the transactions made to it.
+type PayeeNode struct {
+	NodeType
+	Pos
+	End Pos // byte position just after the node, see Span
+	tr  *Tree
+
+	Payee   string
+	Note    string   // Comment on the line of the payee name, like "; online".
+	Aliases []string // Regexps of the descriptions of the transactions made to this payee, like "^AMZN Mktp".
+	UUID    string   // Matches the transactions with this `UUID` metadata.
+
+	SubDirectives []SubDirective // The lines of the block, in the order written.
+}
+
+func (t *Tree) newPayee(p Pos) *PayeeNode {
+	d := &PayeeNode{NodeType: NodePayee, Pos: p, tr: t}
+	t.Root.add(d)
+	return d
+}
+
+func (n *PayeeNode) String() string { return "payee " + n.Payee }
+func (n *PayeeNode) tree() *Tree    { return n.tr }
+func (n *PayeeNode) Span() Span     { return Span{n.Pos, n.End} }
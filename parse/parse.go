@@ -4,19 +4,30 @@ package parse
 import (
 	"fmt"
 	"io/ioutil"
-	"runtime"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
+
+	"github.com/abourget/ledger/period"
 )
 
 // Tree is the representation of a single parsed Ledger file.
 type Tree struct {
 	FileName  string    // name of the template represented by the tree.
 	Root      *ListNode // top-level root of the tree.
+	Mode      Mode      // parsing mode, set before calling Parse.
 	text      string    // text parsed to create the template (or its parent)
 	lex       *lexer
 	token     [3]item // three-token lookahead for parser.
 	peekCount int
+	errors    ErrorList // errors collected in AllErrors mode.
+	lines     []Pos     // offsets of the start of each line, see LineCol.
+	applies   []string  // directives of the `apply` blocks open.
+	year      int       // year of the dates written without one, from the latest `year` directive, or zero.
+	years     []int     // years to restore at the end of the `apply year` blocks open.
 }
 
 func Parse(filename string) (t *Tree, err error) {
@@ -41,61 +52,202 @@ func New(name, input string) *Tree {
 }
 
 // parse is the top-level parser for a Ledger file. It runs to EOF.
-func (t *Tree) Parse() (err error) {
-	defer t.recover(&err)
-
+//
+// By default, it stops at the first error and leaves Root nil.  In
+// AllErrors mode, it returns an ErrorList of all the errors found, and
+// Root holds all the constructs parsed without error.
+func (t *Tree) Parse() error {
 	t.Root = t.newList(Pos(0))
+	t.Root.End = Pos(len(t.text))
+	t.errors = nil
 
 	for t.peek().typ != itemEOF {
-		it := t.next()
-		switch it.typ {
-		case itemError:
-			t.errorf(it.val)
-		case itemSpace, itemEOL:
-			spaceVal := it.val + t.eatSpaces()
-			t.Root.add(t.newSpace(it.pos, spaceVal))
-		case itemComment:
-			t.Root.add(t.newComment(it))
-			t.expect(itemEOL, "comment")
-		case itemEqual:
-			// Analyze an automated transaction
-		case itemTilde:
-			// Analyze a periodic transaction
-		case itemDate:
-			// Analyze a plain transaction
-			txDate, err := parseDate(it.val)
-			if err != nil {
-				t.error(err)
-			}
-			x := t.newXact(it.pos)
-			x.Date = txDate
-			t.parseXact(x)
-		case itemCommodityDirective:
-			d := t.newCommodity(it.pos)
-			t.parseCommodityDirective(d)
-		case itemInclude:
-			d := t.newDirective(it.pos, "include")
-			d.Raw = d.Directive + t.eatSpaces()
-			if it = t.peek(); it.typ != itemString {
-				t.unexpected(it, "include args, expected a string")
-			}
+		if !t.parseTopLevel() {
+			break
+		}
+	}
+
+	if t.Mode&AllErrors != 0 {
+		return t.errors.Err()
+	}
+	if len(t.errors) != 0 {
+		t.Root = nil
+		return t.errors[0]
+	}
+	return nil
+}
+
+// Text returns the input text of the tree, in which node spans are
+// offsets.
+func (t *Tree) Text() string {
+	return t.text
+}
+
+// parseTopLevel parses a top-level construct. It returns false when
+// parsing must stop because of an error.
+func (t *Tree) parseTopLevel() (ok bool) {
+	defer t.recover(len(t.Root.Nodes), &ok)
+
+	it := t.next()
+	switch it.typ {
+	case itemError:
+		t.errorAt(it, nil, "%s", it.val)
+	case itemSpace, itemEOL:
+		spaceVal := it.val + t.eatSpaces()
+		t.Root.add(t.newSpace(it.pos, spaceVal))
+	case itemComment:
+		t.Root.add(t.newComment(it))
+		t.expect(itemEOL, "comment")
+	case itemEqual:
+		x := t.newAutoXact(it.pos)
+		t.parseAutoXact(x)
+		x.End = t.endOf(x.Pos)
+	case itemTilde:
+		x := t.newPeriodicXact(it.pos)
+		t.parsePeriodicXact(x)
+		x.End = t.endOf(x.Pos)
+	case itemDate:
+		// Analyze a plain transaction
+		x := t.newXact(it.pos)
+		x.Date, x.ShortDate = t.parseDate(it)
+		t.parseXact(x)
+		x.End = t.endOf(x.Pos)
+	case itemCommodityDirective:
+		d := t.newCommodity(it.pos)
+		t.parseCommodityDirective(d)
+		d.End = t.endOf(d.Pos)
+	case itemAccountKeyword:
+		d := t.newAccount(it.pos)
+		t.parseAccountDirective(d)
+		d.End = t.endOf(d.Pos)
+	case itemPayeeKeyword:
+		d := t.newPayee(it.pos)
+		t.parsePayeeDirective(d)
+		d.End = t.endOf(d.Pos)
+	case itemInclude:
+		d := t.newDirective(it.pos, "include")
+		d.Raw = d.Directive + t.eatSpaces()
+		if it = t.peek(); it.typ != itemString {
+			t.unexpected(it, "include args, expected a string")
+		}
+		t.next()
+		d.Raw += it.val
+		d.Args = it.val
+		d.End = t.endOf(d.Pos)
+	case itemPrice:
+		p := t.newPrice(it.pos)
+		t.parsePrice(p)
+		p.End = t.endOf(p.Pos)
+	case itemAlias:
+		a := t.newAlias(it.pos)
+		a.Alias = strings.TrimSpace(t.expect(itemAccountName, "alias directive").val)
+		t.expect(itemEqual, "alias directive")
+		a.Account = strings.TrimSpace(t.expect(itemAccountName, "alias directive").val)
+		if it := t.peekNonSpace(); it.typ == itemNote {
 			t.next()
-			d.Raw += it.val
-			d.Args = it.val
-		case itemPrice:
-			d := t.newDirective(it.pos, "P")
-			d.Raw = d.Directive + t.eatSpaces()
-			if it = t.peek(); it.typ != itemString {
-				t.unexpected(it, "P args, expected a string")
-			}
+			a.Note = it.val
+		}
+		t.expectOneOf(itemEOL, itemEOF, "alias directive")
+		a.End = t.endOf(a.Pos)
+	case itemDefaultCommodity:
+		d := t.newDefaultCommodity(it.pos)
+		if next := t.peekNonSpace(); next.typ != itemEOL && next.typ != itemEOF {
+			d.Amount = t.parseAmount()
+		}
+		if d.Amount == nil || d.Amount.Commodity == "" || d.Amount.ValueExpr != "" {
+			t.errorAt(it, nil, "directive 'D' expects an amount with a commodity, like \"D $1,000.00\"")
+		}
+		if it := t.peekNonSpace(); it.typ == itemNote {
 			t.next()
-			d.Raw += it.val
-			d.Args = it.val
+			d.Note = it.val
+		}
+		t.expectOneOf(itemEOL, itemEOF, "default commodity directive")
+		d.End = t.endOf(d.Pos)
+	case itemYear:
+		y := t.newYear(it.pos)
+		y.Directive = it.val
+		y.Year = t.parseYear()
+		t.expectOneOf(itemEOL, itemEOF, "year directive")
+		y.End = t.endOf(y.Pos)
+		t.year = y.Year
+	case itemApply:
+		a := t.newApply(it.pos)
+		switch next := t.nextNonSpace(); next.typ {
+		case itemAccountKeyword:
+			a.Directive = "account"
+			a.Args = strings.TrimSpace(t.expect(itemAccountName, "apply directive").val)
+		case itemYear:
+			a.Directive = "year"
+			year := t.parseYear()
+			a.Args = strconv.Itoa(year)
+			t.years = append(t.years, t.year)
+			t.year = year
 		default:
-			t.errorf("unsupported top-level directive %s", it)
+			t.unexpected(next, "apply directive")
+		}
+		if it := t.peekNonSpace(); it.typ == itemNote {
+			t.next()
+			a.Note = it.val
+		}
+		t.expectOneOf(itemEOL, itemEOF, "apply directive")
+		a.End = t.endOf(a.Pos)
+		t.applies = append(t.applies, a.Directive)
+	case itemEnd:
+		e := t.newEndApply(it.pos)
+		t.expect(itemApply, "end directive")
+		switch next := t.peekNonSpace(); next.typ {
+		case itemAccountKeyword:
+			t.next()
+			e.Directive = "account"
+		case itemYear:
+			t.next()
+			e.Directive = "year"
+		}
+		t.expectOneOf(itemEOL, itemEOF, "end directive")
+		e.End = t.endOf(e.Pos)
+
+		n := len(t.applies)
+		if n == 0 {
+			t.errorAt(it, nil, "'%s' without a matching 'apply'", e)
+		}
+		if e.Directive != "" && t.applies[n-1] != e.Directive {
+			t.errorAt(it, nil, "'%s' closes 'apply %s'", e, t.applies[n-1])
 		}
+		if t.applies[n-1] == "year" {
+			t.year = t.years[len(t.years)-1]
+			t.years = t.years[:len(t.years)-1]
+		}
+		t.applies = t.applies[:n-1]
+	default:
+		t.errorAt(it, nil, "unsupported top-level directive %s", it)
 	}
-	return nil
+	return true
+}
+
+// parsePrice parses the arguments of a `P` directive: a date, an
+// optional time of day, a commodity and its price.
+func (t *Tree) parsePrice(p *PriceNode) {
+	it := t.expect(itemDate, "price directive")
+	p.Date, p.ShortDate = t.parseDate(it)
+
+	if it := t.peekNonSpace(); it.typ == itemTime {
+		t.next()
+		tod, err := parseTime(it.val)
+		if err != nil {
+			t.errorAt(it, nil, "%s", err)
+		}
+		p.Date = p.Date.Add(tod)
+		p.Time = it.val
+	}
+
+	p.Commodity = t.expect(itemCommodity, "price directive").val
+	p.Price = t.parseAmount()
+
+	if it := t.peekNonSpace(); it.typ == itemNote {
+		t.next()
+		p.Note = it.val
+	}
+	t.expectOneOf(itemEOL, itemEOF, "price directive")
 }
 
 func (t *Tree) eatSpaces() string {
@@ -121,11 +273,7 @@ func (t *Tree) parseXact(x *XactNode) {
 		if it.typ != itemDate {
 			t.unexpected(it, "transaction, after '='")
 		}
-		effectiveDate, err := parseDate(it.val)
-		if err != nil {
-			t.error(err)
-		}
-		x.EffectiveDate = effectiveDate
+		x.EffectiveDate, x.ShortEffectiveDate = t.parseDate(it)
 	}
 
 	switch it := t.peekNonSpace(); it.typ {
@@ -153,11 +301,11 @@ func (t *Tree) parseXact(x *XactNode) {
 		t.next()
 		x.Description = it.val
 	case itemAsterisk, itemExclamation:
-		t.errorf("cannot specify cleared and/or pending more than once")
+		t.errorAt(it, nil, "cannot specify cleared and/or pending more than once")
 	case itemNote:
-		t.errorf("missing payee/description before notes")
+		t.errorAt(it, []itemType{itemString}, "missing payee/description before notes")
 	case itemEOL, itemEOF:
-		t.errorf("unexpected end of input")
+		t.errorAt(it, []itemType{itemString}, "unexpected end of input")
 	}
 
 	switch it := t.peekNonSpace(); it.typ {
@@ -176,6 +324,55 @@ func (t *Tree) parseXact(x *XactNode) {
 	t.parsePostings(x)
 }
 
+func (t *Tree) parseAutoXact(x *AutoXactNode) {
+	switch it := t.peekNonSpace(); it.typ {
+	case itemString:
+		t.next()
+		x.Predicate = it.val
+	case itemNote, itemEOL, itemEOF:
+		t.errorAt(it, []itemType{itemString}, "missing predicate for automated transaction")
+	}
+
+	if it := t.peekNonSpace(); it.typ == itemNote {
+		t.next()
+		x.Note = it.val
+	}
+
+	t.expect(itemEOL, "automated transaction opening line")
+
+	x.Predicate, x.NotePreSpace = splitTrailingSpace(x.Predicate)
+
+	t.parsePostings(x)
+}
+
+func (t *Tree) parsePeriodicXact(x *PeriodicXactNode) {
+	expr := t.peekNonSpace()
+	switch expr.typ {
+	case itemString:
+		t.next()
+		x.PeriodExpr = expr.val
+	case itemNote, itemEOL, itemEOF:
+		t.errorAt(expr, []itemType{itemString}, "missing period expression for periodic transaction")
+	}
+
+	if it := t.peekNonSpace(); it.typ == itemNote {
+		t.next()
+		x.Note = it.val
+	}
+
+	t.expect(itemEOL, "periodic transaction opening line")
+
+	x.PeriodExpr, x.NotePreSpace = splitTrailingSpace(x.PeriodExpr)
+
+	p, err := period.Parse(x.PeriodExpr)
+	if err != nil {
+		t.errorAt(expr, nil, "%s", err)
+	}
+	x.Period = p
+
+	t.parsePostings(x)
+}
+
 func (t *Tree) parseCommodityDirective(c *CommodityNode) {
 	it := t.nextNonSpace()
 	if it.typ != itemCommodity {
@@ -190,7 +387,7 @@ func (t *Tree) parseCommodityDirective(c *CommodityNode) {
 		case itemSpace:
 			followsEOL = false
 		case itemEOL:
- 			if followsEOL {
+			if followsEOL {
 				t.backup()
 				return
 			}
@@ -230,7 +427,132 @@ func (t *Tree) parseCommodityDirective(c *CommodityNode) {
 	}
 }
 
-func (t *Tree) parsePostings(x *XactNode) {
+func (t *Tree) parseAccountDirective(a *AccountNode) {
+	it := t.nextNonSpace()
+	if it.typ != itemAccountName {
+		t.errorf("expecting an account name after 'account'")
+	}
+	a.Account = strings.TrimRight(it.val, spaceChars)
+	if it := t.peekNonSpace(); it.typ == itemNote {
+		t.next()
+		a.Note = it.val
+	}
+
+	var followsEOL bool
+
+	for {
+		it := t.next()
+		switch it.typ {
+		case itemSpace:
+			followsEOL = false
+			continue
+		case itemEOL:
+			if followsEOL {
+				t.backup()
+				return
+			}
+			followsEOL = true
+			continue
+		case itemEOF:
+			t.backup()
+			return
+		case itemNote:
+			a.SubDirectives = append(a.SubDirectives, SubDirective{Value: strings.TrimRight(it.val, spaceChars)})
+			continue
+		case itemAccountDefault:
+			a.Default = true
+			a.SubDirectives = append(a.SubDirectives, SubDirective{Keyword: it.val})
+			continue
+		}
+
+		if it.typ <= itemAccountKeywordsStart || it.typ >= itemAccountKeywordsEnd {
+			t.backup()
+			return
+		}
+
+		arg := t.nextNonSpace()
+		if arg.typ != itemString {
+			t.errorf("expecting string after '%s'", it.val)
+		}
+		val := strings.TrimRight(arg.val, spaceChars)
+
+		switch it.typ {
+		case itemAccountNote:
+			a.Notes = append(a.Notes, val)
+		case itemAccountAlias:
+			a.Aliases = append(a.Aliases, val)
+		case itemAccountPayee:
+			a.Payees = append(a.Payees, val)
+		case itemAccountCheck:
+			a.Checks = append(a.Checks, val)
+		case itemAccountAssert:
+			a.Asserts = append(a.Asserts, val)
+		case itemAccountEval:
+			a.Evals = append(a.Evals, val)
+		}
+		a.SubDirectives = append(a.SubDirectives, SubDirective{Keyword: it.val, Value: val})
+	}
+}
+
+func (t *Tree) parsePayeeDirective(p *PayeeNode) {
+	it := t.nextNonSpace()
+	if it.typ != itemString {
+		t.errorf("expecting a payee name after 'payee'")
+	}
+	p.Payee = strings.TrimRight(it.val, spaceChars)
+	if it := t.peekNonSpace(); it.typ == itemNote {
+		t.next()
+		p.Note = it.val
+	}
+
+	var followsEOL bool
+
+	for {
+		it := t.next()
+		switch it.typ {
+		case itemSpace:
+			followsEOL = false
+			continue
+		case itemEOL:
+			if followsEOL {
+				t.backup()
+				return
+			}
+			followsEOL = true
+			continue
+		case itemEOF:
+			t.backup()
+			return
+		case itemNote:
+			p.SubDirectives = append(p.SubDirectives, SubDirective{Value: strings.TrimRight(it.val, spaceChars)})
+			continue
+		}
+
+		if it.typ <= itemPayeeKeywordsStart || it.typ >= itemPayeeKeywordsEnd {
+			t.backup()
+			return
+		}
+
+		arg := t.nextNonSpace()
+		if arg.typ != itemString {
+			t.errorf("expecting string after '%s'", it.val)
+		}
+		val := strings.TrimRight(arg.val, spaceChars)
+
+		switch it.typ {
+		case itemPayeeAlias:
+			if _, err := regexp.Compile(val); err != nil {
+				t.errorAt(arg, nil, "invalid payee alias %q: %s", val, err)
+			}
+			p.Aliases = append(p.Aliases, val)
+		case itemPayeeUUID:
+			p.UUID = val
+		}
+		p.SubDirectives = append(p.SubDirectives, SubDirective{Keyword: it.val, Value: val})
+	}
+}
+
+func (t *Tree) parsePostings(x postingsHolder) {
 	// stop on double EOL, or EOL + Space + EOL
 	var posting *PostingNode
 	for {
@@ -243,16 +565,15 @@ func (t *Tree) parsePostings(x *XactNode) {
 			if it.typ == itemNote {
 				t.next()
 				if posting == nil {
-					// attach to the XactMode
-					if x.Note == "" {
-						x.Note = it.val
-					} else {
-						x.Note = x.Note + "\n" + it.val
-					}
+					// attach to the transaction itself
+					x.appendNote(it.val)
 				} else {
 					posting.Note = posting.Note + "\n" + it.val
 				}
 				t.expect(itemEOL, "comment")
+				if posting != nil {
+					posting.End = t.endOf(posting.Pos)
+				}
 				continue
 			}
 
@@ -260,6 +581,7 @@ func (t *Tree) parsePostings(x *XactNode) {
 			posting.AccountPreSpace = preSpace.val
 
 			t.parsePosting(posting)
+			posting.End = t.endOf(posting.Pos)
 
 		default:
 			return
@@ -325,6 +647,13 @@ func (t *Tree) parsePosting(p *PostingNode) {
 		lastSpace = it.val
 	}
 
+	// Lot annotations come before the price in Ledger, like "-5 AAPL
+	// {140 USD} [2017/05/01] @ 160 USD", but are also accepted after.
+	lotSpace := t.parseLotAnnotations(p)
+	if lotSpace != "" {
+		lastSpace = lotSpace
+	}
+
 	// Parse optional prices '@' and '@@'
 	if it := t.peekNonSpace(); it.typ == itemAt || it.typ == itemDoubleAt {
 		t.next()
@@ -359,25 +688,8 @@ func (t *Tree) parsePosting(p *PostingNode) {
 		lastSpace = it.val
 	}
 
-	if it := t.peekNonSpace(); it.typ == itemLotPrice {
-		t.next()
-		p.LotPrice = t.newAmount()
-		p.LotPrice.Quantity = strings.Trim(it.val, "{}")
-		p.LotPrice.Pos = it.pos
-	}
-
-	if it := t.peek(); it.typ == itemSpace {
-		lastSpace = it.val
-	}
-
-	if it := t.peekNonSpace(); it.typ == itemLotDate {
-		t.next()
-
-		dt, err := parseDate(it.val)
-		if err != nil {
-			t.error(err)
-		}
-		p.LotDate = dt
+	if lotSpace := t.parseLotAnnotations(p); lotSpace != "" {
+		lastSpace = lotSpace
 	}
 
 	if it := t.peek(); it.typ == itemSpace {
@@ -455,9 +767,13 @@ func (t *Tree) parseAmount() (amount *AmountNode) {
 		}
 		amount.next(t)
 		amount.Quantity = it.val
-	case itemLotDate:
+	case itemLotDate, itemLotPrice, itemAt, itemDoubleAt, itemEqual:
+		// Amount without commodity, followed by its annotations, its
+		// price or a balance assertion.
 	case itemNote:
-
+	case itemEOL, itemEOF:
+		// Amount without commodity, like the multipliers of
+		// automated transactions.
 	default:
 		t.unexpected(it, "amount")
 	}
@@ -465,6 +781,70 @@ func (t *Tree) parseAmount() (amount *AmountNode) {
 	return
 }
 
+// parseLotAnnotations parses the optional lot price and lot date of a
+// posting, like "{140 USD} [2017/05/01]", and returns the space
+// following them, if any.
+func (t *Tree) parseLotAnnotations(p *PostingNode) (lastSpace string) {
+	if it := t.peekNonSpace(); it.typ == itemLotPrice {
+		if p.LotPrice != nil {
+			t.errorAt(it, nil, "unexpected lot price (specified twice ?)")
+		}
+		t.next()
+		p.LotPrice = t.parseLotPrice(it)
+	}
+
+	if it := t.peek(); it.typ == itemSpace {
+		lastSpace = it.val
+	}
+
+	if it := t.peekNonSpace(); it.typ == itemLotDate {
+		if !p.LotDate.IsZero() {
+			t.errorAt(it, nil, "unexpected lot date (specified twice ?)")
+		}
+		t.next()
+
+		dt, err := parseDate(it.val)
+		if err != nil {
+			t.error(err)
+		}
+		p.LotDate = dt
+
+		if it := t.peek(); it.typ == itemSpace {
+			lastSpace = it.val
+		}
+	}
+	return lastSpace
+}
+
+// parseLotPrice splits up the amount of a lot price, like "{50 USD}",
+// "{$50}" or "{50}".
+func (t *Tree) parseLotPrice(it item) *AmountNode {
+	a := t.newAmount()
+	a.Pos = it.pos
+	a.End = it.pos + Pos(len(it.val))
+	a.Raw = it.val
+
+	val := strings.TrimSpace(strings.Trim(it.val, "{}"))
+	if strings.HasPrefix(val, "-") {
+		t.errorf("unexpected negative lot price %s", it.val)
+	}
+
+	isQuantity := func(r rune) bool {
+		return unicode.IsDigit(r) || r == '.' || r == ','
+	}
+	start := strings.IndexFunc(val, isQuantity)
+	if start < 0 {
+		t.errorf("missing quantity in lot price %s", it.val)
+	}
+	end := start + strings.IndexFunc(val[start:], func(r rune) bool { return !isQuantity(r) })
+	if end < start {
+		end = len(val)
+	}
+	a.Quantity = val[start:end]
+	a.Commodity = strings.TrimSpace(val[:start] + val[end:])
+	return a
+}
+
 func (t *Tree) append(n Node) {
 	t.Root.Nodes = append(t.Root.Nodes, n)
 }
@@ -479,6 +859,25 @@ func (t *Tree) next() item {
 	return t.token[t.peekCount]
 }
 
+// offset returns the position just after the last consumed token.
+func (t *Tree) offset() Pos {
+	if t.peekCount > 0 {
+		return t.token[t.peekCount-1].pos
+	}
+	return t.token[0].pos + Pos(len(t.token[0].val))
+}
+
+// endOf returns the end of the node starting at `start` and ending
+// with the last consumed token, without the spaces and end of lines
+// that follow it.
+func (t *Tree) endOf(start Pos) Pos {
+	end := t.offset()
+	for end > start && strings.IndexByte(" \t\r\n", t.text[end-1]) >= 0 {
+		end--
+	}
+	return end
+}
+
 // backup backs the input stream up one token.
 func (t *Tree) backup() {
 	t.peekCount++
@@ -536,32 +935,91 @@ func (t *Tree) peekNonSpace() (token item) {
 // The receiver is only used when the node does not have a pointer to the tree inside,
 // which can occur in old code.
 func (t *Tree) ErrorContext(n Node) (location, context string) {
-	pos := int(n.Position())
 	tree := n.tree()
 	if tree == nil {
 		tree = t
 	}
-	text := tree.text[:pos]
-	byteNum := strings.LastIndex(text, "\n")
-	if byteNum == -1 {
-		byteNum = pos // On first line.
-	} else {
-		byteNum++ // After the newline.
-		byteNum = pos - byteNum
-	}
-	lineNum := 1 + strings.Count(text, "\n")
+	line, col := tree.LineCol(n.Position())
 	context = n.String()
 	if len(context) > 20 {
 		context = fmt.Sprintf("%.20s...", context)
 	}
-	return fmt.Sprintf("%s:%d:%d", tree.FileName, lineNum, byteNum), context
+	return fmt.Sprintf("%s:%d:%d", tree.FileName, line, col-1), context
+}
+
+// Location returns the "file:line:column" of node n, in the tree it
+// was parsed from, with a 1-based column like the parse errors.
+func (t *Tree) Location(n Node) string {
+	tree := n.tree()
+	if tree == nil {
+		tree = t
+	}
+	line, col := tree.LineCol(n.Position())
+	return fmt.Sprintf("%s:%d:%d", tree.FileName, line, col)
 }
 
-// errorf formats the error and terminates processing.
+// lineStarts returns the offsets of the start of each line. They are
+// computed once, on first use.
+func (t *Tree) lineStarts() []Pos {
+	if t.lines == nil {
+		t.lines = append(t.lines, 0)
+		for i := 0; i < len(t.text); i++ {
+			if t.text[i] == '\n' {
+				t.lines = append(t.lines, Pos(i+1))
+			}
+		}
+	}
+	return t.lines
+}
+
+// LineCol returns the 1-based line and column, in bytes, of pos.
+func (t *Tree) LineCol(pos Pos) (line, col int) {
+	lines := t.lineStarts()
+	line = sort.Search(len(lines), func(i int) bool { return lines[i] > pos })
+	return line, int(pos-lines[line-1]) + 1
+}
+
+// lineText returns the text of the 1-based line, without its newline.
+func (t *Tree) lineText(line int) string {
+	start := int(t.lineStarts()[line-1])
+	if end := strings.IndexByte(t.text[start:], '\n'); end >= 0 {
+		return t.text[start : start+end]
+	}
+	return t.text[start:]
+}
+
+// errorf formats the error and terminates processing of the current
+// top-level construct.  The error is located at the last item read.
 func (t *Tree) errorf(format string, args ...interface{}) {
-	t.Root = nil
-	format = fmt.Sprintf("ledger: %s:%d: %s", t.FileName, t.lex.lineNumber(), format)
-	panic(fmt.Errorf(format, args...))
+	t.errorAt(item{pos: t.lex.lastPos}, nil, format, args...)
+}
+
+// errorAt formats the error, located at item `it`, and terminates
+// processing of the current top-level construct.  `expected` lists the
+// kinds of items that would have been valid there, if known.
+func (t *Tree) errorAt(it item, expected []itemType, format string, args ...interface{}) {
+	if it.typ == itemError {
+		// The lexer says better what went wrong than the construct
+		// it interrupted.
+		format, args = "%s", []interface{}{it.val}
+	}
+	line, col := t.LineCol(it.pos)
+	err := &Error{
+		File:   t.FileName,
+		Line:   line,
+		Column: col,
+		Offset: int(it.pos),
+		Msg:    fmt.Sprintf(format, args...),
+		source: t.lineText(line),
+		width:  1,
+	}
+	if it.typ != itemError && it.typ != itemEOL && len(it.val) > 1 && !strings.Contains(it.val, "\n") {
+		err.width = len(it.val)
+	}
+	for _, typ := range expected {
+		err.Expected = append(err.Expected, typ.description())
+	}
+	panic(err)
 }
 
 // error terminates processing.
@@ -573,7 +1031,7 @@ func (t *Tree) error(err error) {
 func (t *Tree) expect(expected itemType, context string) item {
 	token := t.nextNonSpace()
 	if token.typ != expected {
-		t.unexpected(token, context)
+		t.errorAt(token, []itemType{expected}, "unexpected %s in %s", token, context)
 	}
 	return token
 }
@@ -582,30 +1040,60 @@ func (t *Tree) expect(expected itemType, context string) item {
 func (t *Tree) expectOneOf(expected1, expected2 itemType, context string) item {
 	token := t.nextNonSpace()
 	if token.typ != expected1 && token.typ != expected2 {
-		t.unexpected(token, context)
+		t.errorAt(token, []itemType{expected1, expected2}, "unexpected %s in %s", token, context)
 	}
 	return token
 }
 
 // unexpected complains about the token and terminates processing.
 func (t *Tree) unexpected(token item, context string) {
-	t.errorf("unexpected %s in %s", token, context)
+	t.errorAt(token, nil, "unexpected %s in %s", token, context)
 }
 
-// recover is the handler that turns panics into returns from the top level of Parse.
-func (t *Tree) recover(errp *error) {
+// recover is the handler that turns the panics of errorf into errors,
+// in parseTopLevel. The nodes added to Root after the first `keep` ones
+// belong to the faulty construct, and are dropped.
+func (t *Tree) recover(keep int, ok *bool) {
 	e := recover()
-	if e != nil {
-		if _, ok := e.(runtime.Error); ok {
-			panic(e)
+	if e == nil {
+		return
+	}
+	err, isErr := e.(*Error)
+	if !isErr {
+		panic(e)
+	}
+	t.errors = append(t.errors, err)
+	t.Root.Nodes = t.Root.Nodes[:keep]
+	if t.Mode&AllErrors == 0 {
+		*ok = false
+		return
+	}
+	t.skipToTopLevel(Pos(err.Offset))
+	*ok = true
+}
+
+// skipToTopLevel discards the items up to the next line that starts a
+// top-level construct, after the line of the error at `pos`.
+func (t *Tree) skipToTopLevel(pos Pos) {
+	lineStart := Pos(strings.LastIndex(t.text[:pos], "\n") + 1)
+	for {
+		it := t.next()
+		if it.typ == itemEOF {
+			t.backup()
+			return
 		}
-		if t != nil {
-			t.lex.drain()
-			//t.stopParse()
+		if it.pos > lineStart && t.text[it.pos-1] == '\n' && it.typ != itemSpace {
+			t.backup()
+			return
 		}
-		*errp = e.(error)
 	}
-	return
+}
+
+// splitTrailingSpace splits s in its text and its trailing spaces, the
+// spaces before a note on the same line.
+func splitTrailingSpace(s string) (text, space string) {
+	text = strings.TrimRight(s, spaceChars)
+	return text, s[len(text):]
 }
 
 func appendComment(orig, new string) string {
@@ -615,6 +1103,54 @@ func appendComment(orig, new string) string {
 	return orig + "\n" + new
 }
 
+// parseTime returns the duration since midnight of a time of day,
+// like "14:30" or "14:30:00".
+func parseTime(input string) (time.Duration, error) {
+	layout := "15:04:05"
+	if strings.Count(input, ":") == 1 {
+		layout = "15:04"
+	}
+	tod, err := time.Parse(layout, input)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q", input)
+	}
+	return tod.Sub(time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC)), nil
+}
+
+// parseYear parses the year of a `year` or `apply year` directive.
+func (t *Tree) parseYear() int {
+	it := t.nextNonSpace()
+	if it.typ != itemString {
+		t.unexpected(it, "year directive, expected a year")
+	}
+	year, err := strconv.Atoi(it.val)
+	if err != nil {
+		t.errorAt(it, nil, "invalid year %q", it.val)
+	}
+	return year
+}
+
+// parseDate parses the date of a transaction or a directive.  A date
+// written without a year, like "03/15", takes the year of the latest
+// `year` or `apply year` directive or, lacking one, the current year,
+// like in Ledger.  It also reports whether the date was written so.
+func (t *Tree) parseDate(it item) (date time.Time, short bool) {
+	input := it.val
+	if strings.Count(strings.NewReplacer("/", "-", ".", "-").Replace(input), "-") == 1 {
+		short = true
+		year := t.year
+		if year == 0 {
+			year = time.Now().Year()
+		}
+		input = strconv.Itoa(year) + "-" + input
+	}
+	date, err := parseDate(input)
+	if err != nil {
+		t.errorAt(it, nil, "%s", err)
+	}
+	return date, short
+}
+
 func parseDate(input string) (time.Time, error) {
 	stdSeparator := strings.Replace(strings.Replace(input, "/", "-", -1), ".", "-", -1)
 	undecorated := strings.Trim(stdSeparator, "[]") // from itemLotPrice
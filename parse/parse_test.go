@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/abourget/ledger/period"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -153,12 +154,59 @@ func TestParseEdgeCases(t *testing.T) {
 	treeToJSON(tree)
 }
 
+func TestParseLots(t *testing.T) {
+	for _, in := range []string{
+		"2017/06/01 Sell\n  Assets:Broker    -5 AAPL {140 USD} [2017/05/01] @ 160 USD  ; note\n",
+		"2017/06/01 Sell\n  Assets:Broker    -5 AAPL @ 160 USD {140 USD} [2017/05/01]  ; note\n",
+	} {
+		tree := New("file.ledger", in)
+		require.NoError(t, tree.Parse(), in)
+		p := tree.Root.Nodes[0].(*XactNode).Postings[0]
+		assert.Equal(t, "140", p.LotPrice.Quantity)
+		assert.Equal(t, "USD", p.LotPrice.Commodity)
+		assert.Equal(t, time.Date(2017, 5, 1, 0, 0, 0, 0, time.UTC), p.LotDate)
+		assert.Equal(t, "160", p.Price.Quantity)
+		assert.Equal(t, "USD", p.Price.Commodity)
+		assert.Equal(t, "; note", p.Note)
+		assert.Equal(t, "  ", p.NotePreSpace)
+	}
+
+	err := New("file.ledger", "2017/06/01 Sell\n  A    -5 AAPL {140 USD} @ 160 USD {140 USD}\n").Parse()
+	assert.Error(t, err)
+}
+
+func TestParseAmountWithoutCommodity(t *testing.T) {
+	tree := New("file.ledger", `2016/09/10 Grocery
+  Assets:Cash      0 = $975
+  Assets:EUR       10 @ 1.10 USD
+  Assets:Broker    -5 {140 USD} [2017/05/01] @@ 800 USD
+  Equity
+`)
+	require.NoError(t, tree.Parse())
+	postings := tree.Root.Nodes[0].(*XactNode).Postings
+
+	assert.Equal(t, "0", postings[0].Amount.Quantity)
+	assert.Equal(t, "", postings[0].Amount.Commodity)
+	assert.Equal(t, "975", postings[0].BalanceAssertion.Quantity)
+	assert.Equal(t, "$", postings[0].BalanceAssertion.Commodity)
+
+	assert.Equal(t, "10", postings[1].Amount.Quantity)
+	assert.Equal(t, "1.10", postings[1].Price.Quantity)
+
+	assert.Equal(t, "5", postings[2].Amount.Quantity)
+	assert.True(t, postings[2].Amount.Negative)
+	assert.Equal(t, "140", postings[2].LotPrice.Quantity)
+	assert.Equal(t, time.Date(2017, 5, 1, 0, 0, 0, 0, time.UTC), postings[2].LotDate)
+	assert.Equal(t, "800", postings[2].Price.Quantity)
+}
+
 func TestParseErrors(t *testing.T) {
 	tests := []struct {
 		input string
 		error string
 	}{
-		{`2016/09/09 * * heya!`, "1: cannot specify cleared and/or pending more than once"},
+		{`2016/09/09 * * heya!`, "1:14: cannot specify cleared and/or pending more than once"},
+		{"\n~ every fortnight\n  A  1 CAD\n", `2:3: period "every fortnight": expected a unit after 'every', got "fortnight"`},
 	}
 
 	for _, test := range tests {
@@ -169,6 +217,213 @@ func TestParseErrors(t *testing.T) {
 	}
 }
 
+func TestSpans(t *testing.T) {
+	input := `; Header
+commodity CAD
+  note Canadian dollars
+
+2016/09/09 * Grocery  ; note
+  Expenses:Food    - 20.00 CAD @ 1.30 USD
+  ; posting note
+  Assets:Cash    2 AAPL {10 USD}
+include other.ledger
+`
+	tree := New("file.ledger", input)
+	require.NoError(t, tree.Parse())
+
+	text := func(n Node) string {
+		span := n.Span()
+		return tree.Text()[span.Start:span.End]
+	}
+
+	var comment *CommentNode
+	var commodity *CommodityNode
+	var xact *XactNode
+	var include *DirectiveNode
+	for _, n := range tree.Root.Nodes {
+		switch n := n.(type) {
+		case *CommentNode:
+			comment = n
+		case *CommodityNode:
+			commodity = n
+		case *XactNode:
+			xact = n
+		case *DirectiveNode:
+			include = n
+		}
+	}
+	require.NotNil(t, xact)
+
+	assert.Equal(t, input, text(tree.Root))
+	assert.Equal(t, "; Header", text(comment))
+	assert.Equal(t, "commodity CAD\n  note Canadian dollars", text(commodity))
+	assert.Equal(t, "2016/09/09 * Grocery  ; note\n  Expenses:Food    - 20.00 CAD @ 1.30 USD\n  ; posting note\n  Assets:Cash    2 AAPL {10 USD}", text(xact))
+	assert.Equal(t, "Expenses:Food    - 20.00 CAD @ 1.30 USD\n  ; posting note", text(xact.Postings[0]))
+	assert.Equal(t, "- 20.00 CAD", text(xact.Postings[0].Amount))
+	assert.Equal(t, "1.30 USD", text(xact.Postings[0].Price))
+	assert.Equal(t, "{10 USD}", text(xact.Postings[1].LotPrice))
+	assert.Equal(t, "include other.ledger", text(include))
+
+	line, col := tree.LineCol(xact.Postings[0].Amount.Pos)
+	assert.Equal(t, 6, line)
+	assert.Equal(t, 20, col)
+}
+
+func TestParseErrorDetails(t *testing.T) {
+	tests := []struct {
+		input    string
+		line     int
+		column   int
+		expected []string
+		snippet  string
+	}{
+		{
+			input:   "; header\n2016/09/09 * * heya!\n",
+			line:    2,
+			column:  14,
+			snippet: "2016/09/09 * * heya!\n             ^\n",
+		},
+		{
+			input:    "2016/09/09 Desc\n\tA\t$12 {{\n",
+			line:     2,
+			column:   8,
+			expected: []string{"end of line"},
+			snippet:  "\tA\t$12 {{\n\t \t    ^\n",
+		},
+		{
+			input:   "2016/13/45 Desc\n",
+			line:    1,
+			column:  1,
+			snippet: "2016/13/45 Desc\n^^^^^^^^^^\n",
+		},
+		{
+			input:    "~\n  A  1 CAD\n",
+			line:     1,
+			column:   2,
+			expected: []string{"string"},
+			snippet:  "~\n ^\n",
+		},
+		{
+			input:    "2016/09/09 (42 Desc\n",
+			line:     1,
+			column:   20,
+			expected: []string{"')'"},
+			snippet:  "2016/09/09 (42 Desc\n                   ^\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			err := New("file.ledger", test.input).Parse()
+			require.Error(t, err)
+
+			perr, ok := err.(*Error)
+			require.True(t, ok)
+			assert.Equal(t, "file.ledger", perr.File)
+			assert.Equal(t, test.line, perr.Line)
+			assert.Equal(t, test.column, perr.Column)
+			assert.Equal(t, test.expected, perr.Expected)
+			assert.Equal(t, test.snippet, perr.Snippet())
+		})
+	}
+}
+
+func TestParseAllErrorsAfterLexError(t *testing.T) {
+	tree := New("file.ledger", `2016/09/09 Buy
+  Assets:Broker  10 AAPL {50
+  Assets:Cash
+
+2016/09/10 Fine
+  A    1 CAD
+  B
+
+2016/09/11 * * Twice cleared
+  A    1 CAD
+  B
+
+include
+2016/09/12 Also fine
+  A    2 CAD
+  B
+`)
+	tree.Mode = AllErrors
+	err := tree.Parse()
+	require.Error(t, err)
+
+	errs, ok := err.(ErrorList)
+	require.True(t, ok)
+	var msgs []string
+	for _, e := range errs {
+		msgs = append(msgs, e.Error())
+	}
+	assert.Equal(t, []string{
+		"ledger: file.ledger:2:26: expected matching '}' for lot price, got U+000A",
+		"ledger: file.ledger:9:14: cannot specify cleared and/or pending more than once",
+		"ledger: file.ledger:13:8: missing filename after 'include'",
+	}, msgs)
+
+	var descriptions []string
+	for _, n := range tree.Root.Nodes {
+		if x, ok := n.(*XactNode); ok {
+			descriptions = append(descriptions, x.Description)
+		}
+	}
+	assert.Equal(t, []string{"Fine", "Also fine"}, descriptions)
+}
+
+func TestLocation(t *testing.T) {
+	tree := New("file.ledger", "; header\n2016/09/09 Desc\n\tA  1 CAD\n  B\n")
+	require.NoError(t, tree.Parse())
+	var x *XactNode
+	for _, n := range tree.Root.Nodes {
+		if n, ok := n.(*XactNode); ok {
+			x = n
+		}
+	}
+	require.NotNil(t, x)
+	assert.Equal(t, "file.ledger:2:1", tree.Location(x))
+	assert.Equal(t, "file.ledger:3:2", tree.Location(x.Postings[0]))
+	assert.Equal(t, "file.ledger:4:3", tree.Location(x.Postings[1]))
+}
+
+func TestParseAllErrors(t *testing.T) {
+	tree := New("file.ledger", `2016/09/09 * * Twice cleared
+  A    1 CAD
+  B
+
+2016/09/10 Fine
+  A    2 CAD
+  B
+
+~ every fortnight
+  A    1 CAD
+
+2016/09/11 Also fine
+  A    3 CAD
+  B
+`)
+	tree.Mode = AllErrors
+	err := tree.Parse()
+	require.Error(t, err)
+
+	errs, ok := err.(ErrorList)
+	require.True(t, ok)
+	require.Len(t, errs, 2)
+	assert.Equal(t, "ledger: file.ledger:1:14: cannot specify cleared and/or pending more than once", errs[0].Error())
+	assert.Equal(t, 9, errs[1].Line)
+	assert.Equal(t, 3, errs[1].Column)
+
+	var descriptions []string
+	for _, n := range tree.Root.Nodes {
+		if x, ok := n.(*XactNode); ok {
+			descriptions = append(descriptions, x.Description)
+		}
+		_, periodic := n.(*PeriodicXactNode)
+		assert.False(t, periodic)
+	}
+	assert.Equal(t, []string{"Fine", "Also fine"}, descriptions)
+}
+
 func treeToJSON(t *Tree) {
 	a, _ := json.MarshalIndent(t.Root, "", "  ")
 	fmt.Println(string(a))
@@ -208,3 +463,346 @@ commodity $
 	require.True(t, ok)
 	assert.Equal(t, "\n", spc.Space)
 }
+
+func TestParseAutoXact(t *testing.T) {
+	tree := New("file.ledger", `= /^Income:Salary/  ; Withholding
+    (Liabilities:Tax)                  (0.1)
+    [Assets:Withheld]                  -0.1
+
+= expr account =~ /Food/
+  ; Applies to groceries too
+    Expenses:Tips   2 CAD
+`)
+	err := tree.Parse()
+	require.NoError(t, err)
+	assert.Len(t, tree.Root.Nodes, 3)
+
+	auto, ok := tree.Root.Nodes[0].(*AutoXactNode)
+	require.True(t, ok)
+	assert.Equal(t, "/^Income:Salary/", auto.Predicate)
+	assert.Equal(t, "  ", auto.NotePreSpace)
+	assert.Equal(t, "; Withholding", auto.Note)
+	require.Len(t, auto.Postings, 2)
+	assert.Equal(t, "(Liabilities:Tax)", auto.Postings[0].Account)
+	assert.Equal(t, "(0.1)", auto.Postings[0].Amount.ValueExpr)
+	assert.Equal(t, "[Assets:Withheld]", auto.Postings[1].Account)
+	assert.Equal(t, "0.1", auto.Postings[1].Amount.Quantity)
+	assert.True(t, auto.Postings[1].Amount.Negative)
+	assert.Equal(t, "", auto.Postings[1].Amount.Commodity)
+
+	auto, ok = tree.Root.Nodes[2].(*AutoXactNode)
+	require.True(t, ok)
+	assert.Equal(t, "expr account =~ /Food/", auto.Predicate)
+	assert.Equal(t, "\n", auto.NotePreSpace)
+	assert.Equal(t, "; Applies to groceries too", auto.Note)
+	require.Len(t, auto.Postings, 1)
+	assert.Equal(t, "2", auto.Postings[0].Amount.Quantity)
+	assert.Equal(t, "CAD", auto.Postings[0].Amount.Commodity)
+}
+
+func TestParsePeriodicXact(t *testing.T) {
+	tree := New("file.ledger", `~ Monthly
+    Expenses:Food          500 CAD
+    Assets
+
+~ every 2 weeks from 2024/01/01  ; Allowance
+    Expenses:Allowance     20 CAD
+    Assets
+`)
+	err := tree.Parse()
+	require.NoError(t, err)
+	assert.Len(t, tree.Root.Nodes, 3)
+
+	x, ok := tree.Root.Nodes[0].(*PeriodicXactNode)
+	require.True(t, ok)
+	assert.Equal(t, "Monthly", x.PeriodExpr)
+	assert.Equal(t, 1, x.Period.Every)
+	assert.Equal(t, period.Month, x.Period.Unit)
+	require.Len(t, x.Postings, 2)
+	assert.Equal(t, "Expenses:Food", x.Postings[0].Account)
+	assert.Equal(t, "500", x.Postings[0].Amount.Quantity)
+
+	x, ok = tree.Root.Nodes[2].(*PeriodicXactNode)
+	require.True(t, ok)
+	assert.Equal(t, "every 2 weeks from 2024/01/01", x.PeriodExpr)
+	assert.Equal(t, "  ", x.NotePreSpace)
+	assert.Equal(t, "; Allowance", x.Note)
+	assert.Equal(t, 2, x.Period.Every)
+	assert.Equal(t, period.Week, x.Period.Unit)
+	assert.Equal(t, time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC), x.Period.Begin)
+}
+
+func TestParsePrice(t *testing.T) {
+	tree := New("file.ledger", `P 2017/06/15 USD 1.30 CAD
+P 2017/06/16 14:30:00 AAPL $142.50  ; close
+P 2017/06/17 "pine apples" -2 EUR`)
+	err := tree.Parse()
+	require.NoError(t, err)
+	require.Len(t, tree.Root.Nodes, 3)
+
+	p, ok := tree.Root.Nodes[0].(*PriceNode)
+	require.True(t, ok)
+	assert.Equal(t, time.Date(2017, 6, 15, 0, 0, 0, 0, time.UTC), p.Date)
+	assert.Equal(t, "", p.Time)
+	assert.Equal(t, "USD", p.Commodity)
+	assert.Equal(t, "1.30", p.Price.Quantity)
+	assert.Equal(t, "CAD", p.Price.Commodity)
+	assert.Equal(t, "P 2017/06/15 USD 1.30 CAD", p.String())
+
+	p, ok = tree.Root.Nodes[1].(*PriceNode)
+	require.True(t, ok)
+	assert.Equal(t, time.Date(2017, 6, 16, 14, 30, 0, 0, time.UTC), p.Date)
+	assert.Equal(t, "14:30:00", p.Time)
+	assert.Equal(t, "AAPL", p.Commodity)
+	assert.Equal(t, "142.50", p.Price.Quantity)
+	assert.Equal(t, "$", p.Price.Commodity)
+	assert.Equal(t, "; close", p.Note)
+
+	p, ok = tree.Root.Nodes[2].(*PriceNode)
+	require.True(t, ok)
+	assert.Equal(t, `"pine apples"`, p.Commodity)
+	assert.True(t, p.Price.Negative)
+	assert.Equal(t, "P 2017/06/17 \"pine apples\" -2 EUR", p.String())
+
+	for _, in := range []string{"P 2017/06/15\n", "P 2017/06/15 USD\n", "P 2017/06/15 25:00 USD 1 CAD\n"} {
+		err := New("file.ledger", in).Parse()
+		assert.Error(t, err, in)
+	}
+}
+
+func TestParseAliasAndApply(t *testing.T) {
+	tree := New("file.ledger", `alias Checking = Assets:Bank:Checking
+apply account Personal
+end apply account
+apply account Business:Shop  ; shop
+end apply
+alias Cash=Assets:Cash ; petty cash
+`)
+	require.NoError(t, tree.Parse())
+	require.Len(t, tree.Root.Nodes, 6)
+
+	alias, ok := tree.Root.Nodes[0].(*AliasNode)
+	require.True(t, ok)
+	assert.Equal(t, "Checking", alias.Alias)
+	assert.Equal(t, "Assets:Bank:Checking", alias.Account)
+
+	apply, ok := tree.Root.Nodes[1].(*ApplyNode)
+	require.True(t, ok)
+	assert.Equal(t, "account", apply.Directive)
+	assert.Equal(t, "Personal", apply.Args)
+
+	end, ok := tree.Root.Nodes[2].(*EndApplyNode)
+	require.True(t, ok)
+	assert.Equal(t, "account", end.Directive)
+
+	apply = tree.Root.Nodes[3].(*ApplyNode)
+	assert.Equal(t, "Business:Shop", apply.Args)
+	assert.Equal(t, "; shop", apply.Note)
+	end = tree.Root.Nodes[4].(*EndApplyNode)
+	assert.Equal(t, "", end.Directive)
+
+	alias = tree.Root.Nodes[5].(*AliasNode)
+	assert.Equal(t, "Assets:Cash", alias.Account)
+	assert.Equal(t, "; petty cash", alias.Note)
+
+	tests := []struct {
+		input string
+		error string
+	}{
+		{"alias Checking\n", "expected '=' in 'alias' directive"},
+		{"alias =Assets\n", "missing alias before '='"},
+		{"apply tag foo\n", "unsupported directive 'apply tag'"},
+		{"end apply\n", "file.ledger:1:1: 'end apply' without a matching 'apply'"},
+		{"end\n", "expected 'apply' after 'end', got ''"},
+	}
+	for _, test := range tests {
+		err := New("file.ledger", test.input).Parse()
+		if assert.Error(t, err, test.input) {
+			assert.Contains(t, err.Error(), test.error)
+		}
+	}
+}
+
+func TestParseDefaultCommodity(t *testing.T) {
+	tree := New("file.ledger", `D $1,000.00
+D 1.000,00 EUR ; euros
+`)
+	require.NoError(t, tree.Parse())
+	require.Len(t, tree.Root.Nodes, 2)
+
+	d, ok := tree.Root.Nodes[0].(*DefaultCommodityNode)
+	require.True(t, ok)
+	assert.Equal(t, "$", d.Amount.Commodity)
+	assert.Equal(t, "1,000.00", d.Amount.Quantity)
+	assert.Equal(t, "D $1,000.00", d.String())
+
+	d = tree.Root.Nodes[1].(*DefaultCommodityNode)
+	assert.Equal(t, "EUR", d.Amount.Commodity)
+	assert.Equal(t, "1.000,00", d.Amount.Quantity)
+	assert.Equal(t, "; euros", d.Note)
+
+	for _, in := range []string{"D\n", "D 1000\n"} {
+		err := New("file.ledger", in).Parse()
+		if assert.Error(t, err, in) {
+			assert.Contains(t, err.Error(), "directive 'D' expects an amount with a commodity")
+		}
+	}
+}
+
+func TestParseYear(t *testing.T) {
+	tree := New("file.ledger", `year 2017
+03/15 Grocery
+  Expenses:Food  10 CAD
+  Assets:Bank
+apply year 2015
+12/31=01/02 Party
+  Expenses:Food  10 CAD
+  Assets:Bank
+P 12/31 USD 1.30 CAD
+end apply year
+2016/01/05 Full date
+  Expenses:Food  10 CAD
+  Assets:Bank
+Y 2018
+1/2 Short
+  Expenses:Food  10 CAD
+  Assets:Bank
+`)
+	require.NoError(t, tree.Parse())
+	require.Len(t, tree.Root.Nodes, 9)
+
+	year, ok := tree.Root.Nodes[0].(*YearNode)
+	require.True(t, ok)
+	assert.Equal(t, "year", year.Directive)
+	assert.Equal(t, 2017, year.Year)
+
+	x := tree.Root.Nodes[1].(*XactNode)
+	assert.Equal(t, time.Date(2017, 3, 15, 0, 0, 0, 0, time.UTC), x.Date)
+	assert.True(t, x.ShortDate)
+
+	apply := tree.Root.Nodes[2].(*ApplyNode)
+	assert.Equal(t, "year", apply.Directive)
+	assert.Equal(t, "2015", apply.Args)
+
+	x = tree.Root.Nodes[3].(*XactNode)
+	assert.Equal(t, time.Date(2015, 12, 31, 0, 0, 0, 0, time.UTC), x.Date)
+	assert.Equal(t, time.Date(2015, 1, 2, 0, 0, 0, 0, time.UTC), x.EffectiveDate)
+	assert.True(t, x.ShortEffectiveDate)
+
+	p := tree.Root.Nodes[4].(*PriceNode)
+	assert.Equal(t, time.Date(2015, 12, 31, 0, 0, 0, 0, time.UTC), p.Date)
+	assert.True(t, p.ShortDate)
+
+	assert.Equal(t, "year", tree.Root.Nodes[5].(*EndApplyNode).Directive)
+
+	x = tree.Root.Nodes[6].(*XactNode)
+	assert.Equal(t, time.Date(2016, 1, 5, 0, 0, 0, 0, time.UTC), x.Date)
+	assert.False(t, x.ShortDate)
+
+	assert.Equal(t, "Y 2018", tree.Root.Nodes[7].String())
+	x = tree.Root.Nodes[8].(*XactNode)
+	assert.Equal(t, time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC), x.Date)
+
+	tests := []struct {
+		input string
+		error string
+	}{
+		{"year\n", "missing year after 'year'"},
+		{"apply year\n", "missing year after 'apply year'"},
+		{"apply year 2017\nend apply account\n", "file.ledger:2:1: 'end apply account' closes 'apply year'"},
+		{"year 2017\n02/30 Payee\n  A  1 CAD\n  B\n", "file.ledger:2:"},
+	}
+	for _, test := range tests {
+		err := New("file.ledger", test.input).Parse()
+		if assert.Error(t, err, test.input) {
+			assert.Contains(t, err.Error(), test.error)
+		}
+	}
+}
+
+func TestParseAccount(t *testing.T) {
+	tree := New("file.ledger", `account Assets:Bank Checking  ; main bank
+  note Main account
+  ; not the savings
+  note Second note
+  alias Checking
+  alias Chk
+  payee ^Hydro
+  payee ^Bell
+  check commodity == "$"
+  assert abs(amount) < 10000
+  eval 1
+  default
+
+account Expenses:Food
+2016/09/09 Payee
+  Expenses:Food  1 CAD
+  Assets:Bank Checking
+`)
+	err := tree.Parse()
+	require.NoError(t, err)
+	assert.Len(t, tree.Root.Nodes, 4)
+
+	acc, ok := tree.Root.Nodes[0].(*AccountNode)
+	require.True(t, ok)
+	assert.Equal(t, "Assets:Bank Checking", acc.Account)
+	assert.Equal(t, "; main bank", acc.Note)
+	assert.Equal(t, []string{"Main account", "Second note"}, acc.Notes)
+	assert.Equal(t, []string{"Checking", "Chk"}, acc.Aliases)
+	assert.Equal(t, []string{"^Hydro", "^Bell"}, acc.Payees)
+	assert.Equal(t, []string{`commodity == "$"`}, acc.Checks)
+	assert.Equal(t, []string{"abs(amount) < 10000"}, acc.Asserts)
+	assert.Equal(t, []string{"1"}, acc.Evals)
+	assert.True(t, acc.Default)
+	require.Len(t, acc.SubDirectives, 11)
+	assert.Equal(t, SubDirective{Keyword: "note", Value: "Main account"}, acc.SubDirectives[0])
+	assert.Equal(t, SubDirective{Value: "; not the savings"}, acc.SubDirectives[1])
+	assert.Equal(t, SubDirective{Keyword: "default"}, acc.SubDirectives[10])
+
+	acc, ok = tree.Root.Nodes[2].(*AccountNode)
+	require.True(t, ok)
+	assert.Equal(t, "Expenses:Food", acc.Account)
+	assert.Nil(t, acc.Payees)
+
+	_, ok = tree.Root.Nodes[3].(*XactNode)
+	require.True(t, ok)
+}
+
+func TestParsePayee(t *testing.T) {
+	tree := New("file.ledger", `payee Amazon  ; online
+  alias ^AMZN Mktp
+  ; the website
+  alias amazon\.com
+  uuid 2a2e21d4
+
+payee Hydro
+2016/09/09 AMZN Mktp US*2K4
+  Expenses:Books  10 CAD
+  Assets:Bank
+`)
+	require.NoError(t, tree.Parse())
+	require.Len(t, tree.Root.Nodes, 4)
+
+	p, ok := tree.Root.Nodes[0].(*PayeeNode)
+	require.True(t, ok)
+	assert.Equal(t, "Amazon", p.Payee)
+	assert.Equal(t, "; online", p.Note)
+	assert.Equal(t, []string{"^AMZN Mktp", `amazon\.com`}, p.Aliases)
+	require.Len(t, p.SubDirectives, 4)
+	assert.Equal(t, SubDirective{Value: "; the website"}, p.SubDirectives[1])
+	assert.Equal(t, "2a2e21d4", p.UUID)
+	assert.Equal(t, "payee Amazon", p.String())
+
+	p, ok = tree.Root.Nodes[2].(*PayeeNode)
+	require.True(t, ok)
+	assert.Equal(t, "Hydro", p.Payee)
+	assert.Nil(t, p.Aliases)
+
+	_, ok = tree.Root.Nodes[3].(*XactNode)
+	require.True(t, ok)
+
+	err := New("file.ledger", "payee Amazon\n  alias ^AMZN (Mktp\n").Parse()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "file.ledger:2:9: invalid payee alias \"^AMZN (Mktp\"")
+	}
+}
@@ -0,0 +1,447 @@
+// Package period parses Ledger period expressions, like "monthly",
+// "every 2 weeks from 2024/01/01" or "quarterly until 2025".
+package period
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Unit is the granularity of a recurrence or of a date in a period
+// expression.
+type Unit int
+
+const (
+	NoUnit Unit = iota
+	Day
+	Week
+	Month
+	Quarter
+	Year
+)
+
+var unitLabel = map[Unit]string{
+	Day:     "day",
+	Week:    "week",
+	Month:   "month",
+	Quarter: "quarter",
+	Year:    "year",
+}
+
+func (u Unit) String() string {
+	if label, ok := unitLabel[u]; ok {
+		return label
+	}
+	return fmt.Sprintf("unit(%d)", int(u))
+}
+
+// add moves t forward by n units.
+func (u Unit) add(t time.Time, n int) time.Time {
+	switch u {
+	case Day:
+		return t.AddDate(0, 0, n)
+	case Week:
+		return t.AddDate(0, 0, 7*n)
+	case Month:
+		return t.AddDate(0, n, 0)
+	case Quarter:
+		return t.AddDate(0, 3*n, 0)
+	case Year:
+		return t.AddDate(n, 0, 0)
+	}
+	return t
+}
+
+// truncate returns the start of the unit containing t.  Weeks start
+// on Sunday, like in Ledger.
+func (u Unit) truncate(t time.Time) time.Time {
+	y, m, d := t.Date()
+	switch u {
+	case Day:
+		return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+	case Week:
+		return time.Date(y, m, d-int(t.Weekday()), 0, 0, 0, 0, t.Location())
+	case Month:
+		return time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+	case Quarter:
+		return time.Date(y, m-(m-1)%3, 1, 0, 0, 0, 0, t.Location())
+	case Year:
+		return time.Date(y, time.January, 1, 0, 0, 0, 0, t.Location())
+	}
+	return t
+}
+
+// Range is a span of time, from Begin inclusively to End exclusively.
+type Range struct {
+	Begin time.Time
+	End   time.Time
+}
+
+// Contains reports whether t falls in the range.
+func (r Range) Contains(t time.Time) bool {
+	return !t.Before(r.Begin) && t.Before(r.End)
+}
+
+// Period is a parsed period expression: an optional recurrence,
+// bounded by optional begin and end dates.
+type Period struct {
+	Every int       // Number of Units between occurrences, zero when the expression does not recur.
+	Unit  Unit      // Unit of the recurrence.
+	Begin time.Time // Inclusive start of the period, zero when unbounded.
+	End   time.Time // Exclusive end of the period, zero when unbounded.
+}
+
+// IsRecurring reports whether the period repeats over time.
+func (p *Period) IsRecurring() bool {
+	return p.Every > 0 && p.Unit != NoUnit
+}
+
+// Next returns the start of the occurrence following the one starting
+// at t. It returns t unchanged for non-recurring periods.
+func (p *Period) Next(t time.Time) time.Time {
+	if !p.IsRecurring() {
+		return t
+	}
+	return p.Unit.add(t, p.Every)
+}
+
+// Ranges returns an iterator over the successive occurrences of the
+// period.  The bounds `first` and `last` are used in place of Begin
+// and End when the period is open-ended; `first` is then aligned on
+// the start of the recurrence unit, and the last occurrence is not
+// cut short at `last`, as Ledger does.  A non-recurring period yields
+// a single range.
+func (p *Period) Ranges(first, last time.Time) *Iterator {
+	it := &Iterator{period: p, next: p.Begin, end: p.End, clip: !p.End.IsZero()}
+	if it.next.IsZero() {
+		it.next = first
+		if p.IsRecurring() {
+			it.next = p.Unit.truncate(first)
+		}
+	}
+	if it.end.IsZero() {
+		it.end = last
+	}
+	return it
+}
+
+// Iterator walks through the ranges of a Period. See Period.Ranges.
+type Iterator struct {
+	period *Period
+	next   time.Time
+	end    time.Time
+	clip   bool // cut the last range at `end`
+	done   bool
+}
+
+// Next returns the next range of the period, and false when there are
+// no more ranges.
+func (it *Iterator) Next() (Range, bool) {
+	if it.done || !it.next.Before(it.end) {
+		return Range{}, false
+	}
+
+	r := Range{Begin: it.next, End: it.end}
+	if it.period.IsRecurring() {
+		it.next = it.period.Next(it.next)
+		if !it.clip || it.next.Before(r.End) {
+			r.End = it.next
+		}
+	} else {
+		it.done = true
+	}
+	return r, true
+}
+
+// Contains reports whether t falls between Begin and End.
+func (p *Period) Contains(t time.Time) bool {
+	if !p.Begin.IsZero() && t.Before(p.Begin) {
+		return false
+	}
+	if !p.End.IsZero() && !t.Before(p.End) {
+		return false
+	}
+	return true
+}
+
+func (p *Period) String() string {
+	var out []string
+	if p.IsRecurring() {
+		if p.Every == 1 {
+			out = append(out, "every "+p.Unit.String())
+		} else {
+			out = append(out, fmt.Sprintf("every %d %ss", p.Every, p.Unit))
+		}
+	}
+	if !p.Begin.IsZero() {
+		out = append(out, "from "+p.Begin.Format("2006/01/02"))
+	}
+	if !p.End.IsZero() {
+		out = append(out, "to "+p.End.Format("2006/01/02"))
+	}
+	return strings.Join(out, " ")
+}
+
+var recurrences = map[string]struct {
+	every int
+	unit  Unit
+}{
+	"daily":     {1, Day},
+	"weekly":    {1, Week},
+	"biweekly":  {2, Week},
+	"monthly":   {1, Month},
+	"bimonthly": {2, Month},
+	"quarterly": {1, Quarter},
+	"yearly":    {1, Year},
+	"annually":  {1, Year},
+}
+
+var units = map[string]Unit{
+	"day":      Day,
+	"days":     Day,
+	"week":     Week,
+	"weeks":    Week,
+	"month":    Month,
+	"months":   Month,
+	"quarter":  Quarter,
+	"quarters": Quarter,
+	"year":     Year,
+	"years":    Year,
+}
+
+// Parse parses a period expression, resolving relative dates, like
+// "last month", against the current time.  See ParseAt.
+func Parse(expr string) (*Period, error) {
+	return ParseAt(expr, time.Now())
+}
+
+// ParseAt parses a period expression, resolving relative dates
+// against `now`.  Keywords are case-insensitive.
+//
+//	period:     recurrence? range?
+//	recurrence: "daily" | "weekly" | "biweekly" | "monthly" | "bimonthly" |
+//	            "quarterly" | "yearly" | "annually" |
+//	            "every" number? unit
+//	range:      "in"? span | ("from" | "since") span | ("to" | "until") span |
+//	            ("from" | "since") span ("to" | "until") span
+//	span:       YYYY | YYYY/MM | YYYY/MM/DD | month-name |
+//	            ("this" | "last" | "next") unit |
+//	            "today" | "yesterday" | "tomorrow"
+//
+// A lone span covers the whole year, month, day, etc. it names.  The
+// end of a range is exclusive: "from 2024 to 2025" covers all of 2024.
+func ParseAt(expr string, now time.Time) (*Period, error) {
+	p := &parser{
+		expr:   expr,
+		tokens: strings.Fields(strings.ToLower(expr)),
+		now:    now,
+	}
+	return p.parse()
+}
+
+type parser struct {
+	expr   string
+	tokens []string
+	now    time.Time
+	period Period
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("period %q: %s", p.expr, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) peek() string {
+	if len(p.tokens) == 0 {
+		return ""
+	}
+	return p.tokens[0]
+}
+
+func (p *parser) next() string {
+	tok := p.peek()
+	if tok != "" {
+		p.tokens = p.tokens[1:]
+	}
+	return tok
+}
+
+func (p *parser) parse() (*Period, error) {
+	if len(p.tokens) == 0 {
+		return nil, p.errorf("empty expression")
+	}
+
+	for len(p.tokens) != 0 {
+		var err error
+		switch tok := p.next(); {
+		case tok == "every":
+			err = p.parseEvery()
+		case recurrences[tok].every != 0:
+			err = p.setRecurrence(recurrences[tok].every, recurrences[tok].unit)
+		case tok == "from" || tok == "since":
+			err = p.parseBegin()
+		case tok == "to" || tok == "until":
+			err = p.parseEnd()
+		case tok == "in":
+			err = p.parseIn(p.next())
+		default:
+			err = p.parseIn(tok)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !p.period.Begin.IsZero() && !p.period.End.IsZero() && !p.period.Begin.Before(p.period.End) {
+		return nil, p.errorf("range ends before it begins")
+	}
+
+	return &p.period, nil
+}
+
+func (p *parser) setRecurrence(every int, unit Unit) error {
+	if p.period.IsRecurring() {
+		return p.errorf("recurrence specified twice")
+	}
+	p.period.Every = every
+	p.period.Unit = unit
+	return nil
+}
+
+func (p *parser) parseEvery() error {
+	every := 1
+	if n, err := strconv.Atoi(p.peek()); err == nil {
+		if n <= 0 {
+			return p.errorf("recurrence must be positive, got %d", n)
+		}
+		p.next()
+		every = n
+	}
+	tok := p.next()
+	unit, ok := units[tok]
+	if !ok {
+		return p.errorf("expected a unit after 'every', got %q", tok)
+	}
+	return p.setRecurrence(every, unit)
+}
+
+func (p *parser) parseBegin() error {
+	if !p.period.Begin.IsZero() {
+		return p.errorf("start date specified twice")
+	}
+	r, err := p.parseSpan(p.next())
+	if err != nil {
+		return err
+	}
+	p.period.Begin = r.Begin
+	return nil
+}
+
+func (p *parser) parseEnd() error {
+	if !p.period.End.IsZero() {
+		return p.errorf("end date specified twice")
+	}
+	r, err := p.parseSpan(p.next())
+	if err != nil {
+		return err
+	}
+	p.period.End = r.Begin
+	return nil
+}
+
+// parseIn handles a lone span, bounding the period on both ends.
+func (p *parser) parseIn(tok string) error {
+	if !p.period.Begin.IsZero() || !p.period.End.IsZero() {
+		return p.errorf("unexpected %q", tok)
+	}
+	r, err := p.parseSpan(tok)
+	if err != nil {
+		return err
+	}
+	p.period.Begin = r.Begin
+	p.period.End = r.End
+	return nil
+}
+
+var relativeDays = map[string]int{
+	"yesterday": -1,
+	"today":     0,
+	"tomorrow":  1,
+}
+
+var relativeOffsets = map[string]int{
+	"last": -1,
+	"this": 0,
+	"next": 1,
+}
+
+// parseSpan parses an absolute or relative date, starting with `tok`,
+// into the range of time it covers.
+func (p *parser) parseSpan(tok string) (Range, error) {
+	y, m, d := p.now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+
+	if days, ok := relativeDays[tok]; ok {
+		begin := today.AddDate(0, 0, days)
+		return Range{begin, Day.add(begin, 1)}, nil
+	}
+
+	if offset, ok := relativeOffsets[tok]; ok {
+		unitTok := p.next()
+		unit, ok := units[unitTok]
+		if !ok {
+			return Range{}, p.errorf("expected a unit after %q, got %q", tok, unitTok)
+		}
+		begin := unit.add(unit.truncate(today), offset)
+		return Range{begin, unit.add(begin, 1)}, nil
+	}
+
+	if month, ok := months[tok]; ok {
+		begin := time.Date(y, month, 1, 0, 0, 0, 0, time.UTC)
+		return Range{begin, Month.add(begin, 1)}, nil
+	}
+
+	begin, unit, err := p.parseDate(tok)
+	if err != nil {
+		return Range{}, err
+	}
+	return Range{begin, unit.add(begin, 1)}, nil
+}
+
+var months = map[string]time.Month{}
+
+func init() {
+	for m := time.January; m <= time.December; m++ {
+		name := strings.ToLower(m.String())
+		months[name] = m
+		months[name[:3]] = m
+	}
+}
+
+// parseDate parses YYYY, YYYY/MM or YYYY/MM/DD, with '/', '-' or '.'
+// as separators, returning the start of the date and its precision.
+func (p *parser) parseDate(tok string) (time.Time, Unit, error) {
+	if tok == "" {
+		return time.Time{}, NoUnit, p.errorf("expected a date")
+	}
+	fields := strings.FieldsFunc(tok, func(r rune) bool {
+		return r == '/' || r == '-' || r == '.'
+	})
+	if len(fields) == 0 || len(fields) > 3 {
+		return time.Time{}, NoUnit, p.errorf("invalid date %q", tok)
+	}
+	parts := []int{0, 1, 1}
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return time.Time{}, NoUnit, p.errorf("invalid date %q", tok)
+		}
+		parts[i] = n
+	}
+	if len(fields[0]) != 4 || parts[1] < 1 || parts[1] > 12 || parts[2] < 1 || parts[2] > 31 {
+		return time.Time{}, NoUnit, p.errorf("invalid date %q", tok)
+	}
+	date := time.Date(parts[0], time.Month(parts[1]), parts[2], 0, 0, 0, 0, time.UTC)
+	return date, []Unit{Year, Month, Day}[len(fields)-1], nil
+}
@@ -0,0 +1,120 @@
+package period
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in     string
+		expect Period
+	}{
+		{"Monthly", Period{Every: 1, Unit: Month}},
+		{"every 2 weeks from 2024/01/01", Period{Every: 2, Unit: Week, Begin: date(2024, 1, 1)}},
+		{"Quarterly until 2025", Period{Every: 1, Unit: Quarter, End: date(2025, 1, 1)}},
+		{"every day", Period{Every: 1, Unit: Day}},
+		{"biweekly since 2024-03", Period{Every: 2, Unit: Week, Begin: date(2024, 3, 1)}},
+		{"from 2024/01 to 2024/06", Period{Begin: date(2024, 1, 1), End: date(2024, 6, 1)}},
+		{"2024/02", Period{Begin: date(2024, 2, 1), End: date(2024, 3, 1)}},
+		{"yearly 2024", Period{Every: 1, Unit: Year, Begin: date(2024, 1, 1), End: date(2025, 1, 1)}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			p, err := Parse(test.in)
+			require.NoError(t, err)
+			assert.Equal(t, test.expect, *p)
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		in    string
+		error string
+	}{
+		{"", `period "": empty expression`},
+		{"every fortnight", `period "every fortnight": expected a unit after 'every', got "fortnight"`},
+		{"monthly weekly", `period "monthly weekly": recurrence specified twice`},
+		{"from 2024/13", `period "from 2024/13": invalid date "2024/13"`},
+		{"from 2025 to 2024", `period "from 2025 to 2024": range ends before it begins`},
+	}
+
+	for _, test := range tests {
+		_, err := Parse(test.in)
+		assert.EqualError(t, err, test.error)
+	}
+}
+
+func TestNext(t *testing.T) {
+	p, err := Parse("every 2 weeks")
+	require.NoError(t, err)
+	assert.Equal(t, date(2024, 1, 15), p.Next(date(2024, 1, 1)))
+
+	p, err = Parse("quarterly")
+	require.NoError(t, err)
+	assert.Equal(t, date(2024, 4, 1), p.Next(date(2024, 1, 1)))
+}
+
+func TestParseRelative(t *testing.T) {
+	now := time.Date(2024, time.May, 15, 13, 0, 0, 0, time.Local) // a Wednesday
+	tests := []struct {
+		in     string
+		expect Period
+	}{
+		{"last quarter", Period{Begin: date(2024, 1, 1), End: date(2024, 4, 1)}},
+		{"this year", Period{Begin: date(2024, 1, 1), End: date(2025, 1, 1)}},
+		{"next month", Period{Begin: date(2024, 6, 1), End: date(2024, 7, 1)}},
+		{"this week", Period{Begin: date(2024, 5, 12), End: date(2024, 5, 19)}},
+		{"yesterday", Period{Begin: date(2024, 5, 14), End: date(2024, 5, 15)}},
+		{"in march", Period{Begin: date(2024, 3, 1), End: date(2024, 4, 1)}},
+		{"weekly from last month", Period{Every: 1, Unit: Week, Begin: date(2024, 4, 1)}},
+		{"since jan until this month", Period{Begin: date(2024, 1, 1), End: date(2024, 5, 1)}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			p, err := ParseAt(test.in, now)
+			require.NoError(t, err)
+			assert.Equal(t, test.expect, *p)
+		})
+	}
+}
+
+func TestRanges(t *testing.T) {
+	collect := func(it *Iterator) (ranges []Range) {
+		for r, ok := it.Next(); ok; r, ok = it.Next() {
+			ranges = append(ranges, r)
+		}
+		return
+	}
+
+	p, err := Parse("every 2 months from 2024/01/01 to 2024/06/15")
+	require.NoError(t, err)
+	assert.Equal(t, []Range{
+		{date(2024, 1, 1), date(2024, 3, 1)},
+		{date(2024, 3, 1), date(2024, 5, 1)},
+		{date(2024, 5, 1), date(2024, 6, 15)},
+	}, collect(p.Ranges(time.Time{}, time.Time{})))
+
+	p, err = Parse("quarterly")
+	require.NoError(t, err)
+	assert.Equal(t, []Range{
+		{date(2024, 1, 1), date(2024, 4, 1)},
+		{date(2024, 4, 1), date(2024, 7, 1)},
+	}, collect(p.Ranges(date(2024, 2, 10), date(2024, 5, 1))))
+
+	p, err = Parse("2024")
+	require.NoError(t, err)
+	assert.Equal(t, []Range{
+		{date(2024, 1, 1), date(2025, 1, 1)},
+	}, collect(p.Ranges(time.Time{}, time.Time{})))
+}
@@ -0,0 +1,214 @@
+// Package prices keeps the history of the prices of commodities, as
+// declared with `P` directives or implied by the `@` and `@@` prices of
+// the postings of a journal.
+package prices
+
+import (
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/abourget/ledger/journal"
+)
+
+// entry is the price of a commodity from a point in time.
+type entry struct {
+	at    time.Time
+	price *big.Rat
+}
+
+// pair is a commodity priced in a target commodity.
+type pair struct {
+	commodity, target string
+}
+
+// PriceDB is a time-indexed history of commodity prices.
+type PriceDB struct {
+	history map[pair][]entry // sorted by time
+
+	// neighbours lists, for each commodity, the commodities it has a
+	// price with, in either direction, sorted by name.  It is built on
+	// the first lookup of a chain of prices, and reset by Add when a new
+	// pair of commodities appears.
+	neighbours map[string][]string
+}
+
+func New() *PriceDB {
+	return &PriceDB{history: make(map[pair][]entry)}
+}
+
+// FromJournal builds the price history of j: the prices of its
+// postings, at the date of their transaction, and the prices declared
+// with `P` directives, which prevail over the former at the same time.
+func FromJournal(j *journal.Journal) (*PriceDB, error) {
+	db := New()
+
+	txs, err := j.Transactions()
+	if err != nil {
+		return nil, err
+	}
+	for _, tx := range txs {
+		for _, p := range tx.Postings() {
+			price, err := p.Price()
+			if err != nil {
+				return nil, err
+			}
+			amount := p.Amount()
+			if price == nil || amount == nil {
+				continue
+			}
+			if amount.Commodity == "" || amount.Commodity == price.Commodity {
+				continue
+			}
+			db.Add(amount.Commodity, price.Commodity, tx.Node.Date, price.Quantity)
+		}
+	}
+
+	prices, err := j.Prices()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range prices {
+		db.Add(p.Commodity, p.Price.Commodity, p.Date, p.Price.Quantity)
+	}
+	return db, nil
+}
+
+// Add records that one unit of commodity is worth price units of
+// target from time at.  It replaces a price recorded at the same time.
+func (db *PriceDB) Add(commodity, target string, at time.Time, price *big.Rat) {
+	k := pair{commodity, target}
+	h := db.history[k]
+	if len(h) == 0 {
+		db.neighbours = nil
+	}
+	i := sort.Search(len(h), func(i int) bool { return !h[i].at.Before(at) })
+	e := entry{at, new(big.Rat).Set(price)}
+	if i < len(h) && h[i].at.Equal(at) {
+		h[i] = e
+		return
+	}
+	h = append(h, entry{})
+	copy(h[i+1:], h[i:])
+	h[i] = e
+	db.history[k] = h
+}
+
+// latest returns the last price of commodity in target recorded at or
+// before at.
+func (db *PriceDB) latest(commodity, target string, at time.Time) (entry, bool) {
+	h := db.history[pair{commodity, target}]
+	i := sort.Search(len(h), func(i int) bool { return h[i].at.After(at) })
+	if i == 0 {
+		return entry{}, false
+	}
+	return h[i-1], true
+}
+
+// Market returns the latest price of commodity recorded at or before
+// at, whatever the commodity it is priced in.  It reports false when
+// commodity has no price yet.
+func (db *PriceDB) Market(commodity string, at time.Time) (string, *big.Rat, bool) {
+	var (
+		target string
+		latest entry
+		found  bool
+	)
+	for k := range db.history {
+		if k.commodity != commodity {
+			continue
+		}
+		e, ok := db.latest(k.commodity, k.target, at)
+		if !ok {
+			continue
+		}
+		if !found || e.at.After(latest.at) || e.at.Equal(latest.at) && k.target < target {
+			target, latest, found = k.target, e, true
+		}
+	}
+	if !found {
+		return "", nil, false
+	}
+	return target, new(big.Rat).Set(latest.price), true
+}
+
+// Price returns the value of one unit of commodity in target at time
+// at, from the latest prices recorded at or before at.  When no price
+// of commodity in target was recorded, it is derived from the price of
+// target in commodity, or from a chain of prices, like AAPL in USD and
+// USD in CAD.  It reports false when the commodities are not related.
+func (db *PriceDB) Price(commodity, target string, at time.Time) (*big.Rat, bool) {
+	if commodity == target {
+		return big.NewRat(1, 1), true
+	}
+	if e, ok := db.latest(commodity, target, at); ok {
+		return new(big.Rat).Set(e.price), true
+	}
+
+	// Search the shortest chain of conversions, preferring the most
+	// recent price between two commodities whichever its direction.
+	neighbours := db.graph()
+	rates := map[string]*big.Rat{commodity: big.NewRat(1, 1)}
+	queue := []string{commodity}
+	for len(queue) != 0 {
+		from := queue[0]
+		queue = queue[1:]
+
+		for _, to := range neighbours[from] {
+			if rates[to] != nil {
+				continue
+			}
+			rate, ok := db.rate(from, to, at)
+			if !ok {
+				continue
+			}
+			rates[to] = rate.Mul(rates[from], rate)
+			if to == target {
+				return rates[to], true
+			}
+			queue = append(queue, to)
+		}
+	}
+	return nil, false
+}
+
+// graph returns the commodities related by a price to each commodity,
+// building them once for all the lookups until the next new pair.
+func (db *PriceDB) graph() map[string][]string {
+	if db.neighbours != nil {
+		return db.neighbours
+	}
+	seen := make(map[pair]bool)
+	neighbours := make(map[string][]string)
+	link := func(from, to string) {
+		if !seen[pair{from, to}] {
+			seen[pair{from, to}] = true
+			neighbours[from] = append(neighbours[from], to)
+		}
+	}
+	for k := range db.history {
+		link(k.commodity, k.target)
+		link(k.target, k.commodity)
+	}
+	for _, tos := range neighbours {
+		sort.Strings(tos)
+	}
+	db.neighbours = neighbours
+	return neighbours
+}
+
+// rate returns the value of one unit of from in to at time at, from the
+// most recent price recorded between them, in either direction.  The
+// price of from in to prevails over the one of to in from at the same
+// time.
+func (db *PriceDB) rate(from, to string, at time.Time) (*big.Rat, bool) {
+	direct, ok := db.latest(from, to, at)
+	inverse, invOK := db.latest(to, from, at)
+	if invOK && inverse.price.Sign() != 0 && (!ok || inverse.at.After(direct.at)) {
+		return new(big.Rat).Inv(inverse.price), true
+	}
+	if !ok {
+		return nil, false
+	}
+	return new(big.Rat).Set(direct.price), true
+}
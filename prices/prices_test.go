@@ -0,0 +1,124 @@
+package prices
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/abourget/ledger/journal"
+	"github.com/abourget/ledger/parse"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestPrice(t *testing.T) {
+	tree := parse.New("file.ledger", `P 2017/06/01 USD 1.30 CAD
+P 2017/06/15 USD 1.35 CAD
+P 2017/06/15 12:00 USD 1.40 CAD
+P 2017/06/10 EUR 1.10 USD
+
+2017/06/05 Buy
+  Assets:Broker    10 AAPL @ 150 USD
+  Assets:Cash
+
+2017/06/20 Buy
+  Assets:Broker    5 AAPL @@ 800 USD
+  Assets:Cash
+
+2017/06/15 Buy
+  Assets:Broker    10 AAPL @ $140
+  Assets:Cash
+`)
+	require.NoError(t, tree.Parse())
+	db, err := FromJournal(journal.NewFromTree(tree))
+	require.NoError(t, err)
+
+	tests := []struct {
+		commodity, target string
+		at                time.Time
+		expect            string // empty when unknown
+	}{
+		{"USD", "CAD", date(2017, 5, 31), ""},
+		{"USD", "CAD", date(2017, 6, 1), "1.30"},
+		{"USD", "CAD", date(2017, 6, 14), "1.30"},
+		{"USD", "CAD", date(2017, 6, 15), "1.35"},
+		{"USD", "CAD", date(2017, 6, 15).Add(12 * time.Hour), "1.40"},
+		{"CAD", "USD", date(2017, 6, 1), "0.77"},
+		{"AAPL", "USD", date(2017, 6, 10), "150.00"},
+		{"AAPL", "USD", date(2017, 6, 20), "160.00"},
+		{"AAPL", "$", date(2017, 6, 20), "140.00"},
+		{"AAPL", "CAD", date(2017, 6, 10), "195.00"},
+		{"EUR", "CAD", date(2017, 6, 10), "1.43"},
+		{"EUR", "AAPL", date(2017, 6, 1), ""},
+		{"CAD", "CAD", date(2017, 1, 1), "1.00"},
+		{"CAD", "GBP", date(2017, 6, 30), ""},
+	}
+	for _, test := range tests {
+		p, ok := db.Price(test.commodity, test.target, test.at)
+		if test.expect == "" {
+			assert.False(t, ok, "%s in %s", test.commodity, test.target)
+			continue
+		}
+		if assert.True(t, ok, "%s in %s", test.commodity, test.target) {
+			assert.Equal(t, test.expect, p.FloatString(2), "%s in %s at %s", test.commodity, test.target, test.at)
+		}
+	}
+}
+
+func TestAdd(t *testing.T) {
+	db := New()
+	db.Add("USD", "CAD", date(2017, 6, 2), big.NewRat(2, 1))
+	db.Add("USD", "CAD", date(2017, 6, 1), big.NewRat(1, 1))
+	db.Add("USD", "CAD", date(2017, 6, 2), big.NewRat(3, 1))
+
+	p, ok := db.Price("USD", "CAD", date(2017, 6, 1))
+	require.True(t, ok)
+	assert.Equal(t, "1", p.RatString())
+
+	p, ok = db.Price("USD", "CAD", date(2017, 6, 3))
+	require.True(t, ok)
+	assert.Equal(t, "3", p.RatString())
+
+	p.SetInt64(10) // the history is not changed
+	p, _ = db.Price("USD", "CAD", date(2017, 6, 3))
+	assert.Equal(t, "3", p.RatString())
+}
+
+func TestAddAfterPrice(t *testing.T) {
+	db := New()
+	db.Add("AAPL", "USD", date(2017, 6, 1), big.NewRat(150, 1))
+
+	_, ok := db.Price("AAPL", "CAD", date(2017, 6, 3))
+	assert.False(t, ok)
+
+	db.Add("CAD", "USD", date(2017, 6, 2), big.NewRat(3, 4))
+	p, ok := db.Price("AAPL", "CAD", date(2017, 6, 3))
+	require.True(t, ok)
+	assert.Equal(t, "200", p.RatString())
+}
+
+func TestMarket(t *testing.T) {
+	db := New()
+	db.Add("AAPL", "USD", date(2017, 6, 1), big.NewRat(150, 1))
+	db.Add("AAPL", "CAD", date(2017, 6, 5), big.NewRat(200, 1))
+	db.Add("USD", "CAD", date(2017, 6, 10), big.NewRat(13, 10))
+
+	target, p, ok := db.Market("AAPL", date(2017, 6, 3))
+	require.True(t, ok)
+	assert.Equal(t, "USD", target)
+	assert.Equal(t, "150", p.RatString())
+
+	target, p, ok = db.Market("AAPL", date(2017, 6, 30))
+	require.True(t, ok)
+	assert.Equal(t, "CAD", target)
+	assert.Equal(t, "200", p.RatString())
+
+	_, _, ok = db.Market("CAD", date(2017, 6, 30))
+	assert.False(t, ok)
+	_, _, ok = db.Market("USD", date(2017, 6, 1))
+	assert.False(t, ok)
+}
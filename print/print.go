@@ -35,10 +35,13 @@ func (p *Printer) Print(buf *bytes.Buffer) error {
 
 	for _, nodeIface := range tree.Root.Nodes {
 		var err error
-		fmt.Printf("MAMA %T\n", nodeIface)
 		switch node := nodeIface.(type) {
 		case *parse.XactNode:
 			p.writePlainXact(buf, node)
+		case *parse.AutoXactNode:
+			p.writeAutoXact(buf, node)
+		case *parse.PeriodicXactNode:
+			p.writePeriodicXact(buf, node)
 		case *parse.CommentNode:
 			_, err = buf.WriteString(node.Comment + "\n")
 		case *parse.SpaceNode:
@@ -47,6 +50,20 @@ func (p *Printer) Print(buf *bytes.Buffer) error {
 			_, err = buf.WriteString(node.Raw)
 		case *parse.CommodityNode:
 			p.writeCommodity(buf, node)
+		case *parse.AccountNode:
+			p.writeAccount(buf, node)
+		case *parse.PayeeNode:
+			p.writePayee(buf, node)
+		case *parse.PriceNode:
+			p.writePrice(buf, node)
+		case *parse.DefaultCommodityNode:
+			p.writeDefaultCommodity(buf, node)
+		case *parse.AliasNode:
+			p.writeDirective(buf, node.String(), node.Note)
+		case *parse.ApplyNode:
+			p.writeDirective(buf, node.String(), node.Note)
+		case *parse.EndApplyNode, *parse.YearNode:
+			_, err = buf.WriteString(node.String() + "\n")
 		default:
 			return fmt.Errorf("unprintable node type %T", nodeIface)
 		}
@@ -73,6 +73,192 @@ commodity $
 2017-01-01 * (kode) Tx
     Account1:Hello World              -$10.00
     Other                             (10.00 $ * 2)
+`,
+		},
+		{
+			"automated",
+			`= /^Income:Salary/ ; Withholding
+  (Liabilities:Tax)     (0.1)
+  [Assets:Withheld]  -0.1
+
+= expr account =~ /Food/
+ Expenses:Tips   2 CAD
+
+= /^Income/
+  ; auto note
+  Assets:Tax   (0.1)
+`,
+			`= /^Income:Salary/ ; Withholding
+    (Liabilities:Tax)                 (0.1)
+    [Assets:Withheld]                 -0.1
+
+= expr account =~ /Food/
+    Expenses:Tips                     2 CAD
+
+= /^Income/
+    ; auto note
+    Assets:Tax                        (0.1)
+`,
+		},
+		{
+			"periodic",
+			`~ Monthly
+  Expenses:Food     500 CAD
+  Assets
+
+~ every 2 weeks from 2024/01/01 ; Allowance
+ Expenses:Allowance  20 CAD
+ Assets
+
+~ Yearly
+  ; budget note
+  Expenses:Gifts  300 CAD
+  Assets
+`,
+			`~ Monthly
+    Expenses:Food                     500 CAD
+    Assets
+
+~ every 2 weeks from 2024/01/01 ; Allowance
+    Expenses:Allowance                20 CAD
+    Assets
+
+~ Yearly
+    ; budget note
+    Expenses:Gifts                    300 CAD
+    Assets
+`,
+		},
+		{
+			"account",
+			`account Assets:Bank Checking   ; main bank
+  default
+  payee ^Hydro
+    ; closed in 2016
+  note Main account
+  alias Checking
+  alias Chk
+  note Second note
+account Expenses:Food
+
+`,
+			`account Assets:Bank Checking  ; main bank
+  default
+  payee ^Hydro
+  ; closed in 2016
+  note Main account
+  alias Checking
+  alias Chk
+  note Second note
+account Expenses:Food
+
+`,
+		},
+		{
+			"prices",
+			`P 2017/06/15 USD   1.30 CAD
+P 2017/06/16 14:30 AAPL $142.50 ; close
+
+`,
+			`P 2017-06-15 USD 1.30 CAD
+P 2017-06-16 14:30 AAPL $142.50  ; close
+
+`,
+		},
+		{
+			"alias and apply account",
+			`alias Checking = Assets:Bank:Checking
+apply account Personal
+2016/09/10 Grocery
+  Expenses:Food   10 CAD
+  Checking
+end apply account
+apply  account  Business ; shop
+end apply
+alias Cash=Assets:Cash   ; petty cash
+`,
+			`alias Checking=Assets:Bank:Checking
+apply account Personal
+2016-09-10 Grocery
+    Expenses:Food                     10 CAD
+    Checking
+end apply account
+apply account Business  ; shop
+end apply
+alias Cash=Assets:Cash  ; petty cash
+`,
+		},
+		{
+			"default commodity",
+			`D $1,000.00
+D   1.000,00 EUR ; euros
+`,
+			`D $1,000.00
+D 1.000,00 EUR  ; euros
+`,
+		},
+		{
+			"year and short dates",
+			`year 2017
+03/15 Grocery
+  Expenses:Food   10 CAD
+  Assets:Bank
+apply year 2015
+12/31=1/2 Party
+  Expenses:Food   10 CAD
+  Assets:Bank
+end apply year
+Y 2018
+`,
+			`year 2017
+03-15 Grocery
+    Expenses:Food                     10 CAD
+    Assets:Bank
+apply year 2015
+12-31 = 01-02 Party
+    Expenses:Food                     10 CAD
+    Assets:Bank
+end apply year
+Y 2018
+`,
+		},
+		{
+			"payee",
+			`payee Amazon ; online
+  uuid 2a2e21d4
+  ; the marketplace
+  alias   ^AMZN Mktp
+payee Hydro
+`,
+			`payee Amazon  ; online
+  uuid 2a2e21d4
+  ; the marketplace
+  alias ^AMZN Mktp
+payee Hydro
+`,
+		},
+		{
+			"decimal comma",
+			`2024/03/01 Gehalt
+  Assets:Girokonto   1.234,56 EUR
+  Income:Gehalt   -1.234,56 EUR
+`,
+			`2024-03-01 Gehalt
+    Assets:Girokonto                   1.234,56 EUR
+    Income:Gehalt                     -1.234,56 EUR
+`,
+		},
+		{
+			"balance assertions",
+			`2016/09/10 * Hi there
+  A   = 23 CAD
+  B   -100 CAD = -200 CAD
+  C
+`,
+			`2016-09-10 * Hi there
+    A                                 = 23 CAD
+    B                                 -100 CAD = -200 CAD
+    C
 `,
 		},
 	}
@@ -86,6 +272,15 @@ commodity $
 			printer.MinimumAccountWidth = 30
 			assert.NoError(t, printer.Print(buf))
 			assert.Equal(t, test.out, buf.String())
+
+			// The printed journal prints back the same.
+			tree = parse.New("filename", test.out)
+			assert.NoError(t, tree.Parse())
+			buf.Reset()
+			printer = New(tree)
+			printer.MinimumAccountWidth = 30
+			assert.NoError(t, printer.Print(buf))
+			assert.Equal(t, test.out, buf.String())
 		})
 	}
 }
@@ -30,25 +30,33 @@ func toDate(t time.Time) string {
 	return t.Format("2006-01-02")
 }
 
-func (p *Printer) commentReturns(node *parse.XactNode, input string) string {
+// toShortDate formats a date written without a year, keeping it so.
+func toShortDate(t time.Time, short bool) string {
+	if short {
+		return t.Format("01-02")
+	}
+	return toDate(t)
+}
+
+func (p *Printer) commentReturns(postings []*parse.PostingNode, input string) string {
 	width := p.PostingsIndent
-	if width == 0 {
-		width = len(node.Postings[0].AccountPreSpace)
+	if width == 0 && len(postings) != 0 {
+		width = len(postings[0].AccountPreSpace)
 	}
 	return strings.Replace(input, "\n", "\n"+strings.Repeat(" ", width), -1)
 }
 
-func (p *Printer) postingAccountPreSpace(node *parse.XactNode, post *parse.PostingNode) string {
+func (p *Printer) postingAccountPreSpace(postings []*parse.PostingNode, post *parse.PostingNode) string {
 	if p.PostingsIndent == 0 {
-		return node.Postings[0].AccountPreSpace
+		return postings[0].AccountPreSpace
 	}
 	return strings.Repeat(" ", p.PostingsIndent)
 }
 
-func (p *Printer) postingAccountPostSpace(node *parse.XactNode, post *parse.PostingNode) string {
+func (p *Printer) postingAccountPostSpace(postings []*parse.PostingNode, post *parse.PostingNode) string {
 	var longestAccountName int
 	var longestQuantity int
-	for _, post := range node.Postings {
+	for _, post := range postings {
 		accountLen := accountLength(post)
 		if accountLen > longestAccountName {
 			longestAccountName = accountLen
@@ -130,11 +138,100 @@ func (p *Printer) writeCommodity(b *bytes.Buffer, x *parse.CommodityNode) {
 	}
 }
 
+func (p *Printer) writePrice(b *bytes.Buffer, x *parse.PriceNode) {
+	b.WriteString("P ")
+	b.WriteString(toShortDate(x.Date, x.ShortDate))
+	if x.Time != "" {
+		b.WriteString(" " + x.Time)
+	}
+	b.WriteString(" " + x.Commodity)
+	b.WriteString(" " + amount(x.Price))
+	if x.Note != "" {
+		b.WriteString("  " + x.Note)
+	}
+	b.WriteString("\n")
+}
+
+func (p *Printer) writeDefaultCommodity(b *bytes.Buffer, x *parse.DefaultCommodityNode) {
+	b.WriteString("D " + amount(x.Amount))
+	if x.Note != "" {
+		b.WriteString("  " + x.Note)
+	}
+	b.WriteString("\n")
+}
+
+// writeDirective writes a one-line directive, followed by its note.
+func (p *Printer) writeDirective(b *bytes.Buffer, directive, note string) {
+	b.WriteString(directive)
+	if note != "" {
+		b.WriteString("  " + note)
+	}
+	b.WriteString("\n")
+}
+
+func (p *Printer) writeAccount(b *bytes.Buffer, x *parse.AccountNode) {
+	p.writeDirective(b, "account "+x.Account, x.Note)
+	if len(x.SubDirectives) != 0 {
+		writeSubDirectives(b, x.SubDirectives)
+		return
+	}
+	// Built without the parser: group the sub-directives by kind.
+	for _, note := range x.Notes {
+		b.WriteString("  note " + note + "\n")
+	}
+	for _, alias := range x.Aliases {
+		b.WriteString("  alias " + alias + "\n")
+	}
+	for _, payee := range x.Payees {
+		b.WriteString("  payee " + payee + "\n")
+	}
+	for _, check := range x.Checks {
+		b.WriteString("  check " + check + "\n")
+	}
+	for _, assert := range x.Asserts {
+		b.WriteString("  assert " + assert + "\n")
+	}
+	for _, eval := range x.Evals {
+		b.WriteString("  eval " + eval + "\n")
+	}
+	if x.Default {
+		b.WriteString("  default\n")
+	}
+}
+
+// writeSubDirectives writes the lines of the block of a directive.
+func writeSubDirectives(b *bytes.Buffer, lines []parse.SubDirective) {
+	for _, l := range lines {
+		switch {
+		case l.Keyword == "":
+			b.WriteString("  " + l.Value + "\n")
+		case l.Value == "":
+			b.WriteString("  " + l.Keyword + "\n")
+		default:
+			b.WriteString("  " + l.Keyword + " " + l.Value + "\n")
+		}
+	}
+}
+
+func (p *Printer) writePayee(b *bytes.Buffer, x *parse.PayeeNode) {
+	p.writeDirective(b, "payee "+x.Payee, x.Note)
+	if len(x.SubDirectives) != 0 {
+		writeSubDirectives(b, x.SubDirectives)
+		return
+	}
+	for _, alias := range x.Aliases {
+		b.WriteString("  alias " + alias + "\n")
+	}
+	if x.UUID != "" {
+		b.WriteString("  uuid " + x.UUID + "\n")
+	}
+}
+
 func (p *Printer) writePlainXact(b *bytes.Buffer, x *parse.XactNode) {
-	b.WriteString(toDate(x.Date))
+	b.WriteString(toShortDate(x.Date, x.ShortDate))
 	if !x.EffectiveDate.IsZero() {
 		b.WriteString(" = ")
-		b.WriteString(toDate(x.EffectiveDate))
+		b.WriteString(toShortDate(x.EffectiveDate, x.ShortEffectiveDate))
 	}
 	if x.IsPending {
 		b.WriteString(" !")
@@ -150,13 +247,39 @@ func (p *Printer) writePlainXact(b *bytes.Buffer, x *parse.XactNode) {
 	b.WriteByte(' ')
 	b.WriteString(x.Description)
 	if x.Note != "" {
-		b.WriteString(x.NotePreSpace)
-		b.WriteString(p.commentReturns(x, x.Note))
+		b.WriteString(p.commentReturns(x.Postings, x.NotePreSpace+x.Note))
+	}
+
+	p.writePostings(b, x.Postings)
+	b.WriteByte('\n')
+}
+
+func (p *Printer) writeAutoXact(b *bytes.Buffer, x *parse.AutoXactNode) {
+	b.WriteString("= ")
+	b.WriteString(x.Predicate)
+	if x.Note != "" {
+		b.WriteString(p.commentReturns(x.Postings, x.NotePreSpace+x.Note))
+	}
+
+	p.writePostings(b, x.Postings)
+	b.WriteByte('\n')
+}
+
+func (p *Printer) writePeriodicXact(b *bytes.Buffer, x *parse.PeriodicXactNode) {
+	b.WriteString("~ ")
+	b.WriteString(x.PeriodExpr)
+	if x.Note != "" {
+		b.WriteString(p.commentReturns(x.Postings, x.NotePreSpace+x.Note))
 	}
 
-	for _, posting := range x.Postings {
+	p.writePostings(b, x.Postings)
+	b.WriteByte('\n')
+}
+
+func (p *Printer) writePostings(b *bytes.Buffer, postings []*parse.PostingNode) {
+	for _, posting := range postings {
 		b.WriteByte('\n')
-		b.WriteString(p.postingAccountPreSpace(x, posting))
+		b.WriteString(p.postingAccountPreSpace(postings, posting))
 		if posting.IsPending {
 			b.WriteString("! ")
 		}
@@ -164,10 +287,10 @@ func (p *Printer) writePlainXact(b *bytes.Buffer, x *parse.XactNode) {
 			b.WriteString("* ")
 		}
 		b.WriteString(posting.Account)
-		b.WriteString(p.postingAccountPostSpace(x, posting))
-		if posting.BalanceAssertion != nil {
+		b.WriteString(p.postingAccountPostSpace(postings, posting))
+		if posting.BalanceAssignment != nil {
 			b.WriteString("= ")
-			b.WriteString(amount(posting.BalanceAssertion))
+			b.WriteString(amount(posting.BalanceAssignment))
 		}
 		if posting.Amount != nil {
 			b.WriteString(amount(posting.Amount))
@@ -196,8 +319,7 @@ func (p *Printer) writePlainXact(b *bytes.Buffer, x *parse.XactNode) {
 		}
 		if posting.Note != "" {
 			b.WriteString(posting.NotePreSpace)
-			b.WriteString(p.commentReturns(x, posting.Note))
+			b.WriteString(p.commentReturns(postings, posting.Note))
 		}
 	}
-	b.WriteByte('\n')
 }
@@ -13,13 +13,23 @@ func FindBudgetTxs(txs []*journal.Transaction) []*journal.Transaction {
 	return filter.New(txs, filter.Note("budget:")).Slice()
 }
 
+// Balance reports, for each budgeted account, the budget minus what
+// was spent since `since`. Budgets are read from periodic
+// transactions (`~ Monthly`), and from monthly transactions tagged
+// with a `budget:` note.
 func Balance(j *journal.Journal, since time.Time) (*reports.BalanceReport, error) {
 	txs, err := j.Transactions()
 	if err != nil {
 		return nil, err
 	}
+	periodic, err := j.PeriodicTransactions()
+	if err != nil {
+		return nil, err
+	}
 
 	sinceNotGiven := since.IsZero()
+	givenSince := since
+	now := time.Now()
 
 	accounts := make(map[string]bool)
 	budget := FindBudgetTxs(txs)
@@ -32,7 +42,6 @@ func Balance(j *journal.Journal, since time.Time) (*reports.BalanceReport, error
 			since = bdate
 		}
 
-		now := time.Now()
 		sy, sm, sd := bdate.Date()
 		ey, em, _ := now.Date()
 		months := time.Month(ey-sy)*12 + em - sm
@@ -42,11 +51,40 @@ func Balance(j *journal.Journal, since time.Time) (*reports.BalanceReport, error
 				continue
 			}
 
-			tx := j.AddTransaction(date, "budget")
-			for _, p := range b.Postings() {
-				a := p.Amount()
-				tx.NewPosting(p.Account()).SetAmount(a.Commodity, a.Quantity)
+			addBudgetTx(j, date, b.Postings())
+		}
+	}
+
+	for _, pt := range periodic {
+		for _, p := range pt.Postings() {
+			accounts[p.Account()] = true
+		}
+
+		first := givenSince
+		if first.IsZero() {
+			first = firstDate(txs)
+		}
+		per := pt.PeriodAt(now)
+		if first.IsZero() && per.Begin.IsZero() {
+			// Nothing to budget from: no `since`, no transactions and
+			// no start date in the period expression.
+			continue
+		}
+
+		if per.End.After(now) {
+			// Budget up to today only, like the actual expenses.
+			clipped := *per
+			clipped.End = now
+			per = &clipped
+		}
+
+		ranges := per.Ranges(first, now)
+		for r, ok := ranges.Next(); ok; r, ok = ranges.Next() {
+			if sinceNotGiven && (since.IsZero() || since.After(r.Begin)) {
+				since = r.Begin
 			}
+			y, m, d := r.Begin.Date()
+			addBudgetTx(j, time.Date(y, m, d, 4, 0, 0, 0, time.Local), pt.Postings())
 		}
 	}
 
@@ -57,3 +95,21 @@ func Balance(j *journal.Journal, since time.Time) (*reports.BalanceReport, error
 	})
 	return bal, err
 }
+
+func addBudgetTx(j *journal.Journal, date time.Time, postings []*journal.Posting) {
+	tx := j.AddTransaction(date, "budget")
+	for _, p := range postings {
+		a := p.Amount()
+		tx.NewPosting(p.Account()).SetAmount(a.Commodity, a.Quantity)
+	}
+}
+
+func firstDate(txs []*journal.Transaction) time.Time {
+	var first time.Time
+	for _, tx := range txs {
+		if first.IsZero() || tx.Node.Date.Before(first) {
+			first = tx.Node.Date
+		}
+	}
+	return first
+}
@@ -0,0 +1,33 @@
+package budget
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/abourget/ledger/journal"
+	"github.com/abourget/ledger/parse"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBalanceUntil(t *testing.T) {
+	y, m, _ := time.Now().Date()
+	spent := time.Date(y, m-2, 2, 0, 0, 0, 0, time.Local)
+
+	tree := parse.New("file.ledger", fmt.Sprintf(`~ Monthly until 2099/01/01
+  Expenses:Food     100 CAD
+  Assets:Budget
+
+%s Grocery
+  Expenses:Food     -20 CAD
+  Assets:Cash
+`, spent.Format("2006/01/02")))
+	require.NoError(t, tree.Parse())
+
+	bal, err := Balance(journal.NewFromTree(tree), time.Time{})
+	require.NoError(t, err)
+	require.NotNil(t, bal.Accounts["Expenses:Food"])
+	// Three months budgeted, up to the current one, not up to 2099.
+	assert.Equal(t, "280 CAD", bal.Accounts["Expenses:Food"].Amounts["CAD"].String())
+}
@@ -2,10 +2,12 @@
 package filter
 
 import (
+	"regexp"
 	"strings"
 	"time"
 
 	"github.com/abourget/ledger/journal"
+	"github.com/abourget/ledger/period"
 )
 
 type FilterFn func(tx *journal.Transaction) bool
@@ -54,6 +56,18 @@ func Until(t time.Time) FilterFn {
 	}
 }
 
+// Period keeps the transactions dated within the period expression
+// `expr`, like "last month" or "from 2024/01 to 2024/06".
+func Period(expr string) (FilterFn, error) {
+	p, err := period.Parse(expr)
+	if err != nil {
+		return nil, err
+	}
+	return func(tx *journal.Transaction) bool {
+		return p.Contains(tx.Node.Date)
+	}, nil
+}
+
 func Account(acc string) FilterFn {
 	return func(tx *journal.Transaction) bool {
 		return tx.Posting(acc) != nil
@@ -72,6 +86,33 @@ func Note(note string) FilterFn {
 	}
 }
 
+// Tag keeps the transactions tagged with `name`, on the transaction
+// itself or on any of its postings.
+func Tag(name string) FilterFn {
+	return TagValue(name, nil)
+}
+
+// TagValue keeps the transactions with a tag `name` whose value matches
+// `re`, on the transaction itself or on any of its postings.  A nil
+// `re` matches any value.
+func TagValue(name string, re *regexp.Regexp) FilterFn {
+	matches := func(tags map[string]journal.TagValue) bool {
+		value, ok := tags[name]
+		return ok && (re == nil || re.MatchString(value.Raw))
+	}
+	return func(tx *journal.Transaction) bool {
+		if matches(tx.Tags()) {
+			return true
+		}
+		for _, p := range tx.Postings() {
+			if matches(p.Tags()) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
 func Not(f FilterFn) FilterFn {
 	return func(tx *journal.Transaction) bool {
 		return !f(tx)
@@ -6,9 +6,11 @@ import (
 	"io"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/abourget/ledger/journal"
 	"github.com/abourget/ledger/lpath"
+	"github.com/abourget/ledger/prices"
 )
 
 type BalanceReport struct {
@@ -44,6 +46,27 @@ func relevant(f func(account string) bool, account string) bool {
 }
 
 func BalanceFiltered(txs []*journal.Transaction, filter func(account string) bool) *BalanceReport {
+	return BalanceWith(txs, BalanceOptions{Filter: filter})
+}
+
+// BalanceOptions tune the accounts of a balance report, and how their
+// amounts are valued.
+type BalanceOptions struct {
+	Filter func(account string) bool // Accounts to report, with their parents. Nil reports all accounts.
+
+	Market   bool            // Value the amounts in the commodity of their latest price.
+	Exchange string          // Value the amounts in this commodity, following chains of prices if needed.
+	At       time.Time       // Date of the prices used to value the amounts. Zero means now.
+	Prices   *prices.PriceDB // Price history used by Market and Exchange, see prices.FromJournal.
+	Styles   *journal.Styles // Styles of the commodities the amounts are valued in, see journal.Journal.Styles.
+}
+
+// BalanceWith sums up the postings of txs per account, like
+// BalanceFiltered, and values the balances at the market prices at
+// opts.At when opts.Market or opts.Exchange are set.  Amounts without a
+// known price are kept in their commodity.
+func BalanceWith(txs []*journal.Transaction, opts BalanceOptions) *BalanceReport {
+	filter := opts.Filter
 	b := &BalanceReport{
 		Accounts: make(map[string]*journal.Account),
 		Total:    journal.NewAccount(""),
@@ -112,9 +135,29 @@ func BalanceFiltered(txs []*journal.Transaction, filter func(account string) boo
 		}
 	}
 
+	v := valuation{prices: opts.Prices, market: opts.Market, exchange: opts.Exchange, styles: opts.Styles}
+	if v.enabled() {
+		at := opts.At
+		if at.IsZero() {
+			at = time.Now()
+		}
+		for name, acc := range b.Accounts {
+			b.Accounts[name] = v.valueAccount(acc, at)
+		}
+		b.Total = v.valueAccount(b.Total, at)
+	}
+
 	return b
 }
 
+// BalancePrintOptions tune how a balance report is printed.
+type BalancePrintOptions struct {
+	Depth   int  // Maximum depth of the accounts shown, like 2 for "Expenses:Food". 0 shows all accounts.
+	Tree    bool // Show a tree of accounts, rather than full account names.
+	Empty   bool // Show the accounts with a zero balance.
+	NoTotal bool // Omit the final total.
+}
+
 func (b *BalanceReport) Print(w io.Writer) error {
 	list := make([]*formattedAccount, 0, len(b.Accounts))
 	tf := formatAccount(b.Total)
@@ -145,3 +188,146 @@ func (b *BalanceReport) Print(w io.Writer) error {
 
 	return nil
 }
+
+// balanceLine is an account as printed: its amounts, one per line, and
+// its label on the last line.
+type balanceLine struct {
+	amounts []string
+	indent  int
+	label   string
+}
+
+// PrintOptions writes the report like Ledger does, with full account
+// names unless opts.Tree is set.  In the tree, an account with a single
+// child and no postings of its own is collapsed with it, like
+// "Expenses:Food".
+func (b *BalanceReport) PrintOptions(w io.Writer, opts BalancePrintOptions) error {
+	var lines []*balanceLine
+	if opts.Tree {
+		lines = b.treeLines(b.tree(opts.Depth), 0, opts)
+	} else {
+		lines = b.flatLines(opts)
+	}
+
+	total := amountLines(b.Total)
+	width := 0
+	for _, l := range append(lines, &balanceLine{amounts: total}) {
+		for _, a := range l.amounts {
+			width = max(width, len(a))
+		}
+	}
+	width = max(width, 20)
+
+	for _, l := range lines {
+		for i, a := range l.amounts {
+			var err error
+			if i == len(l.amounts)-1 {
+				_, err = fmt.Fprintf(w, "%*s  %s%s\n", width, a, strings.Repeat("  ", l.indent), l.label)
+			} else {
+				_, err = fmt.Fprintf(w, "%*s\n", width, a)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if opts.NoTotal {
+		return nil
+	}
+	if _, err := fmt.Fprintln(w, strings.Repeat("-", width)); err != nil {
+		return err
+	}
+	for _, a := range total {
+		if _, err := fmt.Fprintf(w, "%*s\n", width, a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *BalanceReport) flatLines(opts BalancePrintOptions) []*balanceLine {
+	var lines []*balanceLine
+	for name, acc := range b.Accounts {
+		if opts.Depth > 0 && depth(name) > opts.Depth {
+			continue
+		}
+		if !opts.Empty && isZero(acc) {
+			continue
+		}
+		lines = append(lines, &balanceLine{amounts: amountLines(acc), label: name})
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		return lines[i].label < lines[j].label
+	})
+	return lines
+}
+
+// accountNode is an account in the tree of a balance report.
+type accountNode struct {
+	name     string // last part of the account name
+	account  *journal.Account
+	children []*accountNode // sorted by name
+}
+
+// tree arranges the accounts of the report down to maxDepth, if not 0.
+func (b *BalanceReport) tree(maxDepth int) *accountNode {
+	root := &accountNode{}
+	nodes := map[string]*accountNode{"": root}
+
+	var names []string
+	for name := range b.Accounts {
+		if maxDepth <= 0 || depth(name) <= maxDepth {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names) // parents first, children by name
+
+	for _, name := range names {
+		parent := nodes[lpath.Base(name)]
+		if parent == nil {
+			// BalanceFiltered creates all parents, but be lenient with
+			// reports built by hand.
+			parent = root
+		}
+		n := &accountNode{name: strings.TrimPrefix(name, lpath.Base(name)+lpath.Separator), account: b.Accounts[name]}
+		parent.children = append(parent.children, n)
+		nodes[name] = n
+	}
+	return root
+}
+
+func (b *BalanceReport) treeLines(n *accountNode, indent int, opts BalancePrintOptions) []*balanceLine {
+	var lines []*balanceLine
+	for _, child := range n.visibleChildren(opts) {
+		label := child.name
+		for {
+			visible := child.visibleChildren(opts)
+			if len(visible) != 1 || !sameAmounts(child.account, visible[0].account) {
+				break
+			}
+			child = visible[0]
+			label += lpath.Separator + child.name
+		}
+
+		lines = append(lines, &balanceLine{amounts: amountLines(child.account), indent: indent, label: label})
+		lines = append(lines, b.treeLines(child, indent+1, opts)...)
+	}
+	return lines
+}
+
+// visibleChildren returns the children shown in the tree: all of them
+// with opts.Empty, otherwise those with a balance or visible children.
+func (n *accountNode) visibleChildren(opts BalancePrintOptions) []*accountNode {
+	var visible []*accountNode
+	for _, child := range n.children {
+		if opts.Empty || !isZero(child.account) || len(child.visibleChildren(opts)) != 0 {
+			visible = append(visible, child)
+		}
+	}
+	return visible
+}
+
+func depth(account string) int {
+	return strings.Count(account, lpath.Separator) + 1
+}
@@ -0,0 +1,163 @@
+package reports
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBalancePrint(t *testing.T) {
+	txs := transactions(t, `2016/09/09 Grocery
+  Expenses:Food:Groceries     20.00 CAD
+  Assets:Checking
+
+2016/09/10 Exchange
+  Assets:USD        10.00 USD @ 1.30 CAD
+  Assets:Checking
+
+2016/09/11 Refund
+  Expenses:Gifts     -5 CAD
+  Expenses:Gifts      5 CAD
+`)
+	report := Balance(txs)
+
+	tests := []struct {
+		name   string
+		opts   BalancePrintOptions
+		expect string
+	}{
+		{
+			name: "tree",
+			opts: BalancePrintOptions{Tree: true},
+			expect: `          -33.00 CAD
+           10.00 USD  Assets
+          -33.00 CAD    Checking
+           10.00 USD    USD
+           20.00 CAD  Expenses:Food:Groceries
+--------------------
+          -13.00 CAD
+           10.00 USD
+`,
+		},
+		{
+			name: "depth and empty",
+			opts: BalancePrintOptions{Depth: 1, Tree: true, Empty: true, NoTotal: true},
+			expect: `          -33.00 CAD
+           10.00 USD  Assets
+           20.00 CAD  Expenses
+`,
+		},
+		{
+			name: "flat",
+			opts: BalancePrintOptions{Empty: true, NoTotal: true},
+			expect: `          -33.00 CAD
+           10.00 USD  Assets
+          -33.00 CAD  Assets:Checking
+           10.00 USD  Assets:USD
+           20.00 CAD  Expenses
+           20.00 CAD  Expenses:Food
+           20.00 CAD  Expenses:Food:Groceries
+                   0  Expenses:Gifts
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			require.NoError(t, report.PrintOptions(buf, test.opts))
+			assert.Equal(t, test.expect, buf.String())
+		})
+	}
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, report.Print(buf))
+	assert.Equal(t, `-33.00 CAD
+10.00 USD  Assets
+-33.00 CAD  Assets:Checking
+ 10.00 USD  Assets:USD
+ 20.00 CAD  Expenses
+ 20.00 CAD  Expenses:Food
+ 20.00 CAD  Expenses:Food:Groceries
+  0.00 CAD  Expenses:Gifts
+----------
+-13.00 CAD
+10.00 USD  
+`, buf.String())
+}
+
+func TestBalanceValue(t *testing.T) {
+	input := `P 2017/01/01 USD 1.25 CAD
+P 2017/06/01 USD 1.30 CAD
+P 2017/06/01 AAPL 150 USD
+
+2017/05/01 Buy
+  Assets:Broker        10 AAPL @ 140 USD
+  Assets:Broker:Cash
+
+2017/05/02 Deposit
+  Assets:Bank         100 CAD
+  Equity
+`
+	txs := transactions(t, input)
+	db := journalPrices(t, input)
+	styles := journalStyles(t, input)
+
+	tests := []struct {
+		name   string
+		opts   BalanceOptions
+		expect string
+	}{
+		{
+			name: "exchange",
+			opts: BalanceOptions{Exchange: "CAD", At: time.Date(2017, 6, 30, 0, 0, 0, 0, time.UTC), Prices: db},
+			expect: `             230 CAD  Assets
+             100 CAD  Assets:Bank
+             130 CAD  Assets:Broker
+           -1820 CAD  Assets:Broker:Cash
+            -100 CAD  Equity
+--------------------
+             130 CAD
+`,
+		},
+		{
+			name: "exchange at an earlier date",
+			opts: BalanceOptions{Exchange: "CAD", At: time.Date(2017, 5, 15, 0, 0, 0, 0, time.UTC), Prices: db},
+			expect: `             100 CAD  Assets
+             100 CAD  Assets:Bank
+                   0  Assets:Broker
+           -1750 CAD  Assets:Broker:Cash
+            -100 CAD  Equity
+--------------------
+                   0
+`,
+		},
+		{
+			name: "market",
+			opts: BalanceOptions{Market: true, At: time.Date(2017, 6, 30, 0, 0, 0, 0, time.UTC), Prices: db, Styles: styles},
+			expect: `        -1720.00 CAD
+            1500 USD  Assets
+          100.00 CAD  Assets:Bank
+        -1820.00 CAD
+            1500 USD  Assets:Broker
+        -1820.00 CAD  Assets:Broker:Cash
+         -100.00 CAD  Equity
+--------------------
+        -1820.00 CAD
+            1500 USD
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			report := BalanceWith(txs, test.opts)
+			require.NoError(t, report.PrintOptions(buf, BalancePrintOptions{Empty: true}))
+			assert.Equal(t, test.expect, buf.String())
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package reports
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/abourget/ledger/journal"
+	"github.com/abourget/ledger/prices"
+)
+
+// GainsOptions tune the lots listed by Gains.
+type GainsOptions struct {
+	Filter func(account string) bool // Lots to list, held in the account or one of its parents. Nil lists all lots.
+	At     time.Time                 // Date of the prices used to value the open lots. Zero means now.
+	Prices *prices.PriceDB           // Price history used to value the open lots. Nil omits unrealized gains.
+}
+
+// GainsReport lists the gains realized by the sales of lots, and the
+// gains not realized yet on the lots held.
+type GainsReport struct {
+	Sales    []*journal.Sale
+	Holdings []*journal.Holding
+	At       time.Time
+}
+
+// Gains reports the sales and the open lots tracked by
+// journal.Journal.Lots, with the open lots valued at opts.At.
+func Gains(lots *journal.Lots, opts GainsOptions) *GainsReport {
+	g := &GainsReport{At: opts.At}
+	if g.At.IsZero() {
+		g.At = time.Now()
+	}
+
+	matches := func(account string) bool {
+		return opts.Filter == nil || relevant(opts.Filter, account)
+	}
+	for _, s := range lots.Sales {
+		if matches(s.Lot.Account) {
+			g.Sales = append(g.Sales, s)
+		}
+	}
+	if opts.Prices != nil {
+		for _, h := range lots.Unrealized(g.At, opts.Prices.Price) {
+			if matches(h.Lot.Account) {
+				g.Holdings = append(g.Holdings, h)
+			}
+		}
+	}
+	return g
+}
+
+// Print writes the realized gains, one line per sale of a lot, then
+// the unrealized gains, one line per lot held, each followed by the
+// total gain per commodity.
+func (g *GainsReport) Print(w io.Writer) error {
+	const row = "%-10s %-10s %-22s %14s %14s %14s %14s\n"
+	line := func(a *journal.Amount) string {
+		return strings.TrimSpace(a.String())
+	}
+	date := func(t time.Time) string {
+		return t.Format("2006/01/02")
+	}
+	total := func(gains *journal.Account) error {
+		for _, a := range sortedAmounts(gains) {
+			if _, err := fmt.Fprintf(w, row, "", "", "Total", "", "", "", line(a)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if _, err := fmt.Fprintf(w, row, "Acquired", "Sold", "Account", "Quantity", "Cost", "Proceeds", "Gain"); err != nil {
+		return err
+	}
+	gains := journal.NewAccount("")
+	for _, s := range g.Sales {
+		quantity := &journal.Amount{Commodity: s.Lot.Commodity, Quantity: s.Quantity}
+		_, err := fmt.Fprintf(w, row, date(s.Lot.Date), date(s.Date), abbreviate(s.Lot.Account, 22), line(quantity), line(s.Cost), line(s.Proceeds), line(s.Gain))
+		if err != nil {
+			return err
+		}
+		gains.Add(s.Gain)
+	}
+	if err := total(gains); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "\n"+row, "Acquired", "Valued", "Account", "Quantity", "Cost", "Value", "Gain"); err != nil {
+		return err
+	}
+	gains = journal.NewAccount("")
+	for _, h := range g.Holdings {
+		quantity := &journal.Amount{Commodity: h.Lot.Commodity, Quantity: h.Lot.Quantity}
+		_, err := fmt.Fprintf(w, row, date(h.Lot.Date), date(g.At), abbreviate(h.Lot.Account, 22), line(quantity), line(h.Cost), line(h.Value), line(h.Gain))
+		if err != nil {
+			return err
+		}
+		gains.Add(h.Gain)
+	}
+	return total(gains)
+}
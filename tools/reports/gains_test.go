@@ -0,0 +1,50 @@
+package reports
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/abourget/ledger/journal"
+	"github.com/abourget/ledger/parse"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGains(t *testing.T) {
+	input := `P 2017/06/30 AAPL 150 USD
+
+2017/01/10 Buy
+  Assets:Broker    10 AAPL @ 100 USD
+  Assets:Cash
+
+2017/02/10 Buy
+  Assets:Broker    10 AAPL {120 USD}
+  Assets:Cash     -1200 USD
+
+2017/03/10 Sell
+  Assets:Broker    -15 AAPL @ 110 USD
+  Assets:Cash
+`
+	tree := parse.New("file.ledger", input)
+	require.NoError(t, tree.Parse())
+	lots, err := journal.NewFromTree(tree).Lots(journal.FIFO)
+	require.NoError(t, err)
+
+	report := Gains(lots, GainsOptions{At: time.Date(2017, 7, 1, 0, 0, 0, 0, time.UTC), Prices: journalPrices(t, input)})
+	buf := &bytes.Buffer{}
+	require.NoError(t, report.Print(buf))
+	assert.Equal(t, `Acquired   Sold       Account                      Quantity           Cost       Proceeds           Gain
+2017/01/10 2017/03/10 Assets:Broker                 10 AAPL       1000 USD       1100 USD        100 USD
+2017/02/10 2017/03/10 Assets:Broker                  5 AAPL        600 USD        550 USD        -50 USD
+                      Total                                                                       50 USD
+
+Acquired   Valued     Account                      Quantity           Cost          Value           Gain
+2017/02/10 2017/07/01 Assets:Broker                  5 AAPL        600 USD        750 USD        150 USD
+                      Total                                                                      150 USD
+`, buf.String())
+
+	report = Gains(lots, GainsOptions{Filter: func(account string) bool { return account == "Assets:Cash" }})
+	assert.Empty(t, report.Sales)
+	assert.Empty(t, report.Holdings)
+}
@@ -0,0 +1,243 @@
+package reports
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/abourget/ledger/journal"
+	"github.com/abourget/ledger/prices"
+)
+
+// RegisterOptions tune the postings listed by Register, and how they
+// are printed.  Zero widths use the defaults of Ledger's 80 columns
+// layout.
+type RegisterOptions struct {
+	Filter   func(account string) bool // Postings to list, matching the account or one of its parents. Nil lists all postings.
+	Related  bool                      // List the other postings of the transactions matching Filter.
+	Subtotal bool                      // Collapse the postings into one entry per account.
+
+	Market   bool            // Value the amounts in the commodity of their latest price.
+	Exchange string          // Value the amounts in this commodity, following chains of prices if needed.
+	At       time.Time       // Date of the prices used to value the amounts. Zero uses the date of each posting.
+	Prices   *prices.PriceDB // Price history used by Market and Exchange, see prices.FromJournal.
+	Styles   *journal.Styles // Styles of the commodities the amounts are valued in, see journal.Journal.Styles.
+
+	DateWidth    int
+	PayeeWidth   int
+	AccountWidth int
+	AmountWidth  int
+	TotalWidth   int
+}
+
+func (o *RegisterOptions) setDefaults() {
+	defaults := []struct {
+		width *int
+		value int
+	}{
+		{&o.DateWidth, 10},
+		{&o.PayeeWidth, 22},
+		{&o.AccountWidth, 22},
+		{&o.AmountWidth, 12},
+		{&o.TotalWidth, 12},
+	}
+	for _, d := range defaults {
+		if *d.width <= 0 {
+			*d.width = d.value
+		}
+	}
+}
+
+// RegisterEntry is a line of the register: a posting, or the subtotal
+// of an account, with the running total once it is accounted for.
+type RegisterEntry struct {
+	Date    time.Time
+	Payee   string
+	Account string
+	Amount  *journal.Amount
+	Total   []*journal.Amount // sorted by commodity
+}
+
+type RegisterReport struct {
+	Entries []*RegisterEntry
+	Options RegisterOptions
+}
+
+// Register lists the postings of the transactions in date order, with
+// a running total per commodity.  With opts.Market or opts.Exchange,
+// the amounts are valued at the market prices, and the running total
+// sums up those values.  It fails on the postings listed whose amount
+// cannot be known, see journal.Posting.ResolveAmount.
+func Register(txs []*journal.Transaction, opts RegisterOptions) (*RegisterReport, error) {
+	opts.setDefaults()
+	r := &RegisterReport{Options: opts}
+
+	sorted := make([]*journal.Transaction, len(txs))
+	copy(sorted, txs)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Node.Date.Before(sorted[j].Node.Date)
+	})
+
+	v := valuation{prices: opts.Prices, market: opts.Market, exchange: opts.Exchange, styles: opts.Styles}
+	matches := func(p *journal.Posting) bool {
+		return opts.Filter == nil || relevant(opts.Filter, p.Account())
+	}
+
+	var entries []*RegisterEntry
+	for _, tx := range sorted {
+		postings := tx.Postings()
+		if opts.Related {
+			related := false
+			for _, p := range postings {
+				related = related || matches(p)
+			}
+			if !related {
+				continue
+			}
+		}
+
+		for _, p := range postings {
+			if matches(p) == opts.Related {
+				continue
+			}
+			amount, err := p.ResolveAmount()
+			if err != nil {
+				return nil, err
+			}
+			at := opts.At
+			if at.IsZero() {
+				at = tx.Node.Date
+			}
+			amount = v.value(amount, at)
+			entries = append(entries, &RegisterEntry{
+				Date:    tx.Node.Date,
+				Payee:   p.Payee(),
+				Account: p.Account(),
+				Amount:  amount,
+			})
+		}
+	}
+
+	if opts.Subtotal {
+		entries = subtotals(entries)
+	}
+
+	totals := journal.NewAccount("")
+	for _, e := range entries {
+		totals.Add(e.Amount)
+		e.Total = sortedAmounts(totals)
+	}
+
+	r.Entries = entries
+	return r, nil
+}
+
+// subtotals collapses entries into one per account and commodity,
+// dated at the first posting, with the last date as payee.
+func subtotals(entries []*RegisterEntry) []*RegisterEntry {
+	if len(entries) == 0 {
+		return nil
+	}
+	first, last := entries[0].Date, entries[len(entries)-1].Date
+
+	byKey := make(map[[2]string]*RegisterEntry)
+	var out []*RegisterEntry
+	for _, e := range entries {
+		key := [2]string{e.Account, e.Amount.Commodity}
+		sub, ok := byKey[key]
+		if !ok {
+			sub = &RegisterEntry{
+				Date:    first,
+				Payee:   "- " + last.Format("2006/01/02"),
+				Account: e.Account,
+				Amount:  &journal.Amount{Commodity: e.Amount.Commodity, Quantity: new(big.Rat), Style: e.Amount.Style},
+			}
+			byKey[key] = sub
+			out = append(out, sub)
+		}
+		sub.Amount.Quantity.Add(sub.Amount.Quantity, e.Amount.Quantity)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Account < out[j].Account
+	})
+	return out
+}
+
+// sortedAmounts returns a copy of the amounts of totals, sorted by
+// commodity, without the zero amounts unless there is only one.
+func sortedAmounts(totals *journal.Account) []*journal.Amount {
+	out := make([]*journal.Amount, 0, len(totals.Amounts))
+	for _, a := range totals.Amounts {
+		if a.Quantity.Sign() == 0 && len(totals.Amounts) > 1 {
+			continue
+		}
+		out = append(out, &journal.Amount{Commodity: a.Commodity, Quantity: new(big.Rat).Set(a.Quantity), Style: a.Style})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Commodity < out[j].Commodity
+	})
+	return out
+}
+
+// Print writes the register in columns: date, payee, account, amount
+// and running total.  Running totals in several commodities take one
+// line per commodity.
+func (r *RegisterReport) Print(w io.Writer) error {
+	o := r.Options
+	o.setDefaults()
+	for _, e := range r.Entries {
+		totals := make([]string, len(e.Total))
+		for i, t := range e.Total {
+			totals[i] = strings.TrimSpace(t.String())
+		}
+		if len(totals) == 0 {
+			totals = []string{"0"}
+		}
+
+		_, err := fmt.Fprintf(w, "%-*s %-*s %-*s %*s %*s\n",
+			o.DateWidth, truncate(e.Date.Format("2006/01/02"), o.DateWidth),
+			o.PayeeWidth, truncate(e.Payee, o.PayeeWidth),
+			o.AccountWidth, abbreviate(e.Account, o.AccountWidth),
+			o.AmountWidth, truncate(strings.TrimSpace(e.Amount.String()), o.AmountWidth),
+			o.TotalWidth, truncate(totals[0], o.TotalWidth))
+		if err != nil {
+			return err
+		}
+
+		indent := o.DateWidth + o.PayeeWidth + o.AccountWidth + o.AmountWidth + 4
+		for _, t := range totals[1:] {
+			if _, err := fmt.Fprintf(w, "%s%*s\n", strings.Repeat(" ", indent), o.TotalWidth, truncate(t, o.TotalWidth)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// truncate shortens s to width runes, ending it with ".." when cut.
+func truncate(s string, width int) string {
+	r := []rune(s)
+	if len(r) <= width {
+		return s
+	}
+	if width <= 2 {
+		return string(r[:width])
+	}
+	return string(r[:width-2]) + ".."
+}
+
+// abbreviate shortens an account name to width runes, keeping its most
+// specific part, like "..:Food:Groceries".
+func abbreviate(account string, width int) string {
+	r := []rune(account)
+	if len(r) <= width {
+		return account
+	}
+	if width <= 2 {
+		return string(r[len(r)-width:])
+	}
+	return ".." + string(r[len(r)-width+2:])
+}
@@ -0,0 +1,152 @@
+package reports
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/abourget/ledger/journal"
+	"github.com/abourget/ledger/parse"
+	"github.com/abourget/ledger/prices"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func transactions(t *testing.T, input string) []*journal.Transaction {
+	tree := parse.New("file.ledger", input)
+	require.NoError(t, tree.Parse())
+	txs, err := journal.NewFromTree(tree).Transactions()
+	require.NoError(t, err)
+	return txs
+}
+
+// journalPrices returns the price history of the journal in input.
+func journalPrices(t *testing.T, input string) *prices.PriceDB {
+	tree := parse.New("file.ledger", input)
+	require.NoError(t, tree.Parse())
+	db, err := prices.FromJournal(journal.NewFromTree(tree))
+	require.NoError(t, err)
+	return db
+}
+
+// journalStyles returns the styles of the commodities of the journal in
+// input.
+func journalStyles(t *testing.T, input string) *journal.Styles {
+	tree := parse.New("file.ledger", input)
+	require.NoError(t, tree.Parse())
+	return journal.NewFromTree(tree).Styles()
+}
+
+const registerInput = `2016/09/11 Grocery
+  Expenses:Food     5 CAD
+  Assets:Checking
+
+2016/09/09 Grocery
+  Expenses:Food     20.00 CAD
+  Assets:Checking
+
+2016/09/10 Exchange
+  Assets:USD        10.00 USD @ 1.30 CAD
+  Assets:Checking
+`
+
+func TestRegister(t *testing.T) {
+	txs := transactions(t, registerInput)
+	db := journalPrices(t, registerInput)
+	styles := journalStyles(t, registerInput)
+	food := regexp.MustCompile("(?i)food").MatchString
+
+	tests := []struct {
+		name   string
+		opts   RegisterOptions
+		expect string
+	}{
+		{
+			name: "filtered",
+			opts: RegisterOptions{Filter: food, PayeeWidth: 10, AccountWidth: 10, AmountWidth: 10, TotalWidth: 10},
+			expect: `2016/09/09 Grocery    ..ses:Food  20.00 CAD  20.00 CAD
+2016/09/11 Grocery    ..ses:Food   5.00 CAD  25.00 CAD
+`,
+		},
+		{
+			name: "related",
+			opts: RegisterOptions{Filter: food, Related: true, PayeeWidth: 10, AccountWidth: 16, AmountWidth: 10, TotalWidth: 10},
+			expect: `2016/09/09 Grocery    Assets:Checking  -20.00 CAD -20.00 CAD
+2016/09/11 Grocery    Assets:Checking   -5.00 CAD -25.00 CAD
+`,
+		},
+		{
+			name: "subtotal",
+			opts: RegisterOptions{Subtotal: true, PayeeWidth: 12, AccountWidth: 16, AmountWidth: 10, TotalWidth: 10},
+			expect: `2016/09/09 - 2016/09/11 Assets:Checking  -38.00 CAD -38.00 CAD
+2016/09/09 - 2016/09/11 Assets:USD        10.00 USD -38.00 CAD
+                                                     10.00 USD
+2016/09/09 - 2016/09/11 Expenses:Food     25.00 CAD -13.00 CAD
+                                                     10.00 USD
+`,
+		},
+		{
+			name: "exchange",
+			opts: RegisterOptions{Filter: regexp.MustCompile("^Assets").MatchString, Exchange: "CAD", Prices: db, Styles: styles, PayeeWidth: 10, AccountWidth: 16, AmountWidth: 10, TotalWidth: 10},
+			expect: `2016/09/09 Grocery    Assets:Checking  -20.00 CAD -20.00 CAD
+2016/09/10 Exchange   Assets:USD        13.00 CAD  -7.00 CAD
+2016/09/10 Exchange   Assets:Checking  -13.00 CAD -20.00 CAD
+2016/09/11 Grocery    Assets:Checking   -5.00 CAD -25.00 CAD
+`,
+		},
+		{
+			name: "exchange at",
+			opts: RegisterOptions{Filter: regexp.MustCompile("USD").MatchString, Exchange: "CAD", At: time.Date(2016, 9, 9, 0, 0, 0, 0, time.UTC), Prices: db, Styles: styles, PayeeWidth: 10, AccountWidth: 16, AmountWidth: 10, TotalWidth: 10},
+			expect: `2016/09/10 Exchange   Assets:USD        10.00 USD  10.00 USD
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reg, err := Register(txs, test.opts)
+			require.NoError(t, err)
+			buf := &bytes.Buffer{}
+			require.NoError(t, reg.Print(buf))
+			assert.Equal(t, test.expect, buf.String())
+		})
+	}
+}
+
+func TestRegisterPayees(t *testing.T) {
+	txs := transactions(t, `payee Amazon
+  alias ^AMZN Mktp
+
+2016/09/09 AMZN Mktp US*2K4
+  Expenses:Books    10 CAD
+  Assets:Checking   ; Payee: Refund
+`)
+	reg, err := Register(txs, RegisterOptions{PayeeWidth: 10, AccountWidth: 16, AmountWidth: 10, TotalWidth: 10})
+	require.NoError(t, err)
+	buf := &bytes.Buffer{}
+	require.NoError(t, reg.Print(buf))
+	assert.Equal(t, `2016/09/09 Amazon     Expenses:Books       10 CAD     10 CAD
+2016/09/09 Refund     Assets:Checking     -10 CAD      0 CAD
+`, buf.String())
+}
+
+func TestRegisterErrors(t *testing.T) {
+	tree := parse.New("file.ledger", `2016/09/09 Exchange
+  Assets:USD        10 USD
+  Assets:CAD       -13 CAD
+  Assets:Checking
+`)
+	require.NoError(t, tree.Parse())
+	txs, err := journal.NewFromTree(tree).Transactions()
+	require.NoError(t, err)
+
+	_, err = Register(txs, RegisterOptions{})
+	assert.EqualError(t, err, "file.ledger:4:3: implicit amount spans several commodities: 13 CAD, -10 USD")
+
+	reg, err := Register(txs, RegisterOptions{Filter: regexp.MustCompile("USD").MatchString, PayeeWidth: 10, AccountWidth: 10, AmountWidth: 5, TotalWidth: 5})
+	require.NoError(t, err)
+	buf := &bytes.Buffer{}
+	require.NoError(t, reg.Print(buf))
+	assert.Equal(t, "2016/09/09 Exchange   Assets:USD 10 .. 10 ..\n", buf.String())
+}
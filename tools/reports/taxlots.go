@@ -0,0 +1,100 @@
+package reports
+
+import (
+	"encoding/csv"
+	"io"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/abourget/ledger/journal"
+	"github.com/abourget/ledger/period"
+)
+
+// TaxLotOptions tune the dispositions listed by TaxLots.
+type TaxLotOptions struct {
+	Filter        func(account string) bool // Lots to list, held in the account or one of its parents. Nil lists all lots.
+	Period        *period.Period            // Dispositions to list, by date of sale. Nil lists all of them.
+	LongTermAfter int                       // Holding period, in months, after which a gain is long-term. Zero means 12.
+}
+
+// TaxLot is the disposition of a lot, as declared for capital gains.
+type TaxLot struct {
+	Account   string
+	Commodity string
+	Quantity  *big.Rat
+	Acquired  time.Time
+	Sold      time.Time
+	Proceeds  *journal.Amount
+	Cost      *journal.Amount // Cost basis.
+	Gain      *journal.Amount // Proceeds less Cost, negative for a loss.
+	LongTerm  bool            // Held for more than the holding period.
+}
+
+type TaxLotReport struct {
+	Lots []*TaxLot
+}
+
+// TaxLots lists the sales of lots tracked by journal.Journal.Lots, one
+// per lot sold, in date order.
+func TaxLots(lots *journal.Lots, opts TaxLotOptions) *TaxLotReport {
+	months := opts.LongTermAfter
+	if months <= 0 {
+		months = 12
+	}
+
+	r := &TaxLotReport{}
+	for _, s := range lots.Sales {
+		if opts.Filter != nil && !relevant(opts.Filter, s.Lot.Account) {
+			continue
+		}
+		if opts.Period != nil && !opts.Period.Contains(s.Date) {
+			continue
+		}
+		r.Lots = append(r.Lots, &TaxLot{
+			Account:   s.Lot.Account,
+			Commodity: s.Lot.Commodity,
+			Quantity:  s.Quantity,
+			Acquired:  s.Lot.Date,
+			Sold:      s.Date,
+			Proceeds:  s.Proceeds,
+			Cost:      s.Cost,
+			Gain:      s.Gain,
+			LongTerm:  s.Date.After(s.Lot.Date.AddDate(0, months, 0)),
+		})
+	}
+	return r
+}
+
+// WriteCSV writes the report as CSV, with a header line.  Quantities
+// are written without their commodity, given in a column of its own,
+// for spreadsheets.
+func (r *TaxLotReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"Account", "Quantity", "Commodity", "Acquired", "Sold", "Proceeds", "Cost Basis", "Gain", "Currency", "Term"})
+	for _, l := range r.Lots {
+		term := "short"
+		if l.LongTerm {
+			term = "long"
+		}
+		cw.Write([]string{
+			l.Account,
+			decimal(l.Quantity),
+			l.Commodity,
+			l.Acquired.Format("2006-01-02"),
+			l.Sold.Format("2006-01-02"),
+			decimal(l.Proceeds.Quantity),
+			decimal(l.Cost.Quantity),
+			decimal(l.Gain.Quantity),
+			l.Gain.Commodity,
+			term,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// decimal writes q as a decimal number, like journal.Amount does.
+func decimal(q *big.Rat) string {
+	return strings.TrimSpace(journal.Amount{Quantity: q}.String())
+}
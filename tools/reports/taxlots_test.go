@@ -0,0 +1,55 @@
+package reports
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/abourget/ledger/journal"
+	"github.com/abourget/ledger/parse"
+	"github.com/abourget/ledger/period"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaxLots(t *testing.T) {
+	tree := parse.New("file.ledger", `2017/01/10 Buy
+  Assets:Broker    10 AAPL @ 100 USD
+  Assets:Cash
+
+2017/06/10 Buy
+  Assets:Broker    10 AAPL @ 120.50 USD
+  Assets:Cash
+
+2018/01/10 Sell
+  Assets:Broker    -4 AAPL @ 110 USD
+  Assets:Cash
+
+2018/01/11 Sell
+  Assets:Broker    -10 AAPL @ 110 USD
+  Assets:Cash
+
+2019/03/01 Sell
+  Assets:Broker    -6 AAPL @ 130 USD
+  Assets:Cash
+`)
+	require.NoError(t, tree.Parse())
+	lots, err := journal.NewFromTree(tree).Lots(journal.FIFO)
+	require.NoError(t, err)
+
+	in2018, err := period.Parse("2018")
+	require.NoError(t, err)
+	report := TaxLots(lots, TaxLotOptions{Period: in2018})
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, report.WriteCSV(buf))
+	assert.Equal(t, `Account,Quantity,Commodity,Acquired,Sold,Proceeds,Cost Basis,Gain,Currency,Term
+Assets:Broker,4,AAPL,2017-01-10,2018-01-10,440,400,40,USD,short
+Assets:Broker,6,AAPL,2017-01-10,2018-01-11,660,600,60,USD,long
+Assets:Broker,4,AAPL,2017-06-10,2018-01-11,440,482,-42,USD,short
+`, buf.String())
+
+	report = TaxLots(lots, TaxLotOptions{LongTermAfter: 6})
+	require.Len(t, report.Lots, 4)
+	assert.True(t, report.Lots[2].LongTerm)
+	assert.Equal(t, "57", report.Lots[3].Gain.Quantity.RatString())
+}
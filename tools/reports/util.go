@@ -1,6 +1,7 @@
 package reports
 
 import (
+	"sort"
 	"strings"
 
 	"github.com/abourget/ledger/journal"
@@ -26,7 +27,7 @@ func formatAccount(acc *journal.Account) *formattedAccount {
 		Account: acc,
 		Name:    acc.Name,
 	}
-	for _, a := range acc.Amounts {
+	for _, a := range sortedAccountAmounts(acc) {
 		v := a.String()
 		f.String += v + "\n"
 		f.Lines++
@@ -37,3 +38,43 @@ func formatAccount(acc *journal.Account) *formattedAccount {
 	f.String = strings.TrimSpace(f.String) + "  " + f.Name
 	return f
 }
+
+// amountLines returns the non-zero amounts of an account, sorted by
+// commodity, or "0" if there are none.
+func amountLines(acc *journal.Account) []string {
+	var lines []string
+	for _, a := range sortedAccountAmounts(acc) {
+		if a.Quantity.Sign() != 0 {
+			lines = append(lines, strings.TrimSpace(a.String()))
+		}
+	}
+	if len(lines) == 0 {
+		return []string{"0"}
+	}
+	return lines
+}
+
+func sortedAccountAmounts(acc *journal.Account) []*journal.Amount {
+	amounts := make([]*journal.Amount, 0, len(acc.Amounts))
+	for _, a := range acc.Amounts {
+		amounts = append(amounts, a)
+	}
+	sort.Slice(amounts, func(i, j int) bool {
+		return amounts[i].Commodity < amounts[j].Commodity
+	})
+	return amounts
+}
+
+func isZero(acc *journal.Account) bool {
+	for _, a := range acc.Amounts {
+		if a.Quantity.Sign() != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// sameAmounts tells if two accounts have the same balance.
+func sameAmounts(a, b *journal.Account) bool {
+	return strings.Join(amountLines(a), "\n") == strings.Join(amountLines(b), "\n")
+}
@@ -0,0 +1,56 @@
+package reports
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/abourget/ledger/journal"
+	"github.com/abourget/ledger/prices"
+)
+
+// valuation converts amounts to their market value, like Ledger's
+// `-V` and `-X` options.
+type valuation struct {
+	prices   *prices.PriceDB
+	market   bool
+	exchange string
+	styles   *journal.Styles
+}
+
+// enabled tells whether amounts are converted at all.
+func (v valuation) enabled() bool {
+	return v.prices != nil && (v.market || v.exchange != "")
+}
+
+// value returns the value of a at time at: in v.exchange if set, or
+// else in the commodity it was last priced in.  Amounts without a
+// known price are returned as is.
+func (v valuation) value(a *journal.Amount, at time.Time) *journal.Amount {
+	if !v.enabled() || a == nil {
+		return a
+	}
+
+	var target string
+	var price *big.Rat
+	var ok bool
+	if v.exchange != "" {
+		target = v.exchange
+		price, ok = v.prices.Price(a.Commodity, target, at)
+	} else {
+		target, price, ok = v.prices.Market(a.Commodity, at)
+	}
+	if !ok {
+		return a
+	}
+	return &journal.Amount{Commodity: target, Quantity: price.Mul(price, a.Quantity), Style: v.styles.Style(target)}
+}
+
+// valueAccount returns a copy of acc with its amounts valued at time
+// at, merging those converted to the same commodity.
+func (v valuation) valueAccount(acc *journal.Account, at time.Time) *journal.Account {
+	out := journal.NewAccount(acc.Name)
+	for _, a := range acc.Amounts {
+		out.Add(v.value(a, at))
+	}
+	return out
+}
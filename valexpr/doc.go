@@ -0,0 +1,9 @@
+// Package valexpr evaluates Ledger value expressions, like the
+// `(123 + 2 * 3 USD)` amounts of postings.
+//
+// Expressions support arithmetic on amounts, comparisons, logical
+// operators, regexp matches (`payee =~ /Store/`), the conditional
+// operator (`a ? b : c`), dates within brackets, variables provided by
+// an Env, and the functions abs, round, floor, ceiling, quantity,
+// commodity and market.
+package valexpr
@@ -0,0 +1,337 @@
+package valexpr
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Value is the result of an expression: an Amount, a string, a
+// time.Time, a bool or a *regexp.Regexp.
+type Value interface{}
+
+// Amount is a quantity of a commodity. An empty Commodity is a plain
+// number, which combines with any commodity.
+type Amount struct {
+	Quantity  *big.Rat
+	Commodity string
+}
+
+func (a Amount) String() string {
+	q := strings.TrimRight(a.Quantity.FloatString(10), "0")
+	q = strings.TrimRight(q, ".")
+	if a.Commodity == "" {
+		return q
+	}
+	return q + " " + a.Commodity
+}
+
+// Env holds what an expression can refer to.  A nil *Env is valid and
+// provides nothing but the built-in variables `today` and `now`.
+type Env struct {
+	// Vars are the variables, like `amount`, `date`, `payee` or
+	// `account`.
+	Vars map[string]Value
+
+	// Market converts an amount to the `target` commodity at the given
+	// time, for the `market` function.  An empty target means the
+	// default commodity.  When nil, amounts are left unconverted.
+	Market func(a Amount, at time.Time, target string) (Amount, error)
+
+	// Precision returns the number of decimals to use when rounding a
+	// commodity.  When nil, amounts are rounded to 2 decimals.
+	Precision func(commodity string) int
+}
+
+func (env *Env) lookup(name string) (Value, bool) {
+	if env != nil {
+		if v, ok := env.Vars[name]; ok {
+			return v, true
+		}
+	}
+	switch name {
+	case "today":
+		now := time.Now().UTC()
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC), true
+	case "now":
+		return time.Now(), true
+	}
+	return nil, false
+}
+
+func (env *Env) precision(commodity string) int {
+	if env == nil || env.Precision == nil {
+		return 2
+	}
+	return env.Precision(commodity)
+}
+
+// Eval parses and evaluates expr in env.
+func Eval(expr string, env *Env) (Value, error) {
+	e, err := Parse(expr)
+	if err != nil {
+		return nil, err
+	}
+	return e.Eval(env)
+}
+
+// Eval evaluates the expression in env.
+func (e *Expr) Eval(env *Env) (Value, error) {
+	return e.root.eval(e, env)
+}
+
+func (e *Expr) errorf(n node, format string, args ...interface{}) error {
+	return &Error{Expr: e.src, Pos: n.position(), Msg: fmt.Sprintf(format, args...)}
+}
+
+func (n *literalNode) eval(e *Expr, env *Env) (Value, error) {
+	return copyValue(n.val), nil
+}
+
+func (n *variableNode) eval(e *Expr, env *Env) (Value, error) {
+	v, ok := env.lookup(n.name)
+	if !ok {
+		return nil, e.errorf(n, "unknown variable %q", n.name)
+	}
+	return copyValue(v), nil
+}
+
+// copyValue makes sure operators, which work in place, never modify the
+// quantity of a literal or of a variable.
+func copyValue(v Value) Value {
+	if a, ok := v.(Amount); ok && a.Quantity != nil {
+		return Amount{new(big.Rat).Set(a.Quantity), a.Commodity}
+	}
+	return v
+}
+
+func (n *ternaryNode) eval(e *Expr, env *Env) (Value, error) {
+	cond, err := n.cond.eval(e, env)
+	if err != nil {
+		return nil, err
+	}
+	if truthy(cond) {
+		return n.x.eval(e, env)
+	}
+	return n.y.eval(e, env)
+}
+
+func (n *unaryNode) eval(e *Expr, env *Env) (Value, error) {
+	x, err := n.x.eval(e, env)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "!" {
+		return !truthy(x), nil
+	}
+	a, ok := x.(Amount)
+	if !ok {
+		return nil, e.errorf(n, "cannot negate %s", describe(x))
+	}
+	a.Quantity.Neg(a.Quantity)
+	return a, nil
+}
+
+func (n *binaryNode) eval(e *Expr, env *Env) (Value, error) {
+	x, err := n.x.eval(e, env)
+	if err != nil {
+		return nil, err
+	}
+	// Logical operators short-circuit, and return the deciding operand.
+	switch n.op {
+	case "&":
+		if !truthy(x) {
+			return x, nil
+		}
+		return n.y.eval(e, env)
+	case "|":
+		if truthy(x) {
+			return x, nil
+		}
+		return n.y.eval(e, env)
+	}
+
+	y, err := n.y.eval(e, env)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "+", "-", "*", "/":
+		return e.arithmetic(n, x, y)
+	case "=~":
+		return e.match(n, x, y)
+	}
+	return e.compare(n, x, y)
+}
+
+func (e *Expr) arithmetic(n *binaryNode, x, y Value) (Value, error) {
+	if xs, ok := x.(string); ok && n.op == "+" {
+		if ys, ok := y.(string); ok {
+			return xs + ys, nil
+		}
+	}
+	a, aok := x.(Amount)
+	b, bok := y.(Amount)
+	if !aok || !bok {
+		return nil, e.errorf(n, "cannot compute %s %s %s", describe(x), n.op, describe(y))
+	}
+
+	res := Amount{Quantity: new(big.Rat)}
+	switch n.op {
+	case "+", "-":
+		commodity, err := e.combine(n, a, b)
+		if err != nil {
+			return nil, err
+		}
+		res.Commodity = commodity
+		if n.op == "+" {
+			res.Quantity.Add(a.Quantity, b.Quantity)
+		} else {
+			res.Quantity.Sub(a.Quantity, b.Quantity)
+		}
+	case "*":
+		if a.Commodity != "" && b.Commodity != "" {
+			return nil, e.errorf(n, "cannot multiply %s by %s", a, b)
+		}
+		res.Commodity = a.Commodity + b.Commodity
+		res.Quantity.Mul(a.Quantity, b.Quantity)
+	case "/":
+		if b.Quantity.Sign() == 0 {
+			return nil, e.errorf(n, "division by zero")
+		}
+		switch {
+		case a.Commodity == b.Commodity:
+			// A ratio, like 10 USD / 4 USD.
+		case b.Commodity == "":
+			res.Commodity = a.Commodity
+		default:
+			return nil, e.errorf(n, "cannot divide %s by %s", a, b)
+		}
+		res.Quantity.Quo(a.Quantity, b.Quantity)
+	}
+	return res, nil
+}
+
+// combine returns the commodity resulting from adding, subtracting or
+// comparing a and b.
+func (e *Expr) combine(n node, a, b Amount) (string, error) {
+	switch {
+	case a.Commodity == b.Commodity, b.Commodity == "":
+		return a.Commodity, nil
+	case a.Commodity == "":
+		return b.Commodity, nil
+	}
+	return "", e.errorf(n, "mismatched commodities %q and %q", a.Commodity, b.Commodity)
+}
+
+func (e *Expr) compare(n *binaryNode, x, y Value) (Value, error) {
+	var cmp int
+	switch a := x.(type) {
+	case Amount:
+		b, ok := y.(Amount)
+		if !ok {
+			return nil, e.errorf(n, "cannot compare %s with %s", describe(x), describe(y))
+		}
+		if _, err := e.combine(n, a, b); err != nil {
+			return nil, err
+		}
+		cmp = a.Quantity.Cmp(b.Quantity)
+	case string:
+		b, ok := y.(string)
+		if !ok {
+			return nil, e.errorf(n, "cannot compare %s with %s", describe(x), describe(y))
+		}
+		cmp = strings.Compare(a, b)
+	case time.Time:
+		b, ok := y.(time.Time)
+		if !ok {
+			return nil, e.errorf(n, "cannot compare %s with %s", describe(x), describe(y))
+		}
+		switch {
+		case a.Before(b):
+			cmp = -1
+		case a.After(b):
+			cmp = 1
+		}
+	case bool:
+		b, ok := y.(bool)
+		if !ok || (n.op != "==" && n.op != "!=") {
+			return nil, e.errorf(n, "cannot compare %s with %s", describe(x), describe(y))
+		}
+		if a != b {
+			cmp = 1
+		}
+	default:
+		return nil, e.errorf(n, "cannot compare %s", describe(x))
+	}
+
+	switch n.op {
+	case "==":
+		return cmp == 0, nil
+	case "!=":
+		return cmp != 0, nil
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	}
+	return cmp >= 0, nil
+}
+
+func (e *Expr) match(n *binaryNode, x, y Value) (Value, error) {
+	var re *regexp.Regexp
+	switch pattern := y.(type) {
+	case *regexp.Regexp:
+		re = pattern
+	case string:
+		var err error
+		if re, err = regexp.Compile(pattern); err != nil {
+			return nil, e.errorf(n, "invalid regexp: %s", err)
+		}
+	default:
+		return nil, e.errorf(n, "cannot match against %s", describe(y))
+	}
+	s, ok := x.(string)
+	if !ok {
+		if a, isAmount := x.(Amount); isAmount {
+			s = a.String()
+		} else {
+			return nil, e.errorf(n, "cannot match %s", describe(x))
+		}
+	}
+	return re.MatchString(s), nil
+}
+
+// truthy tells if v counts as true in a condition: true, non-zero
+// amounts, non-empty strings and any date or regexp.
+func truthy(v Value) bool {
+	switch vv := v.(type) {
+	case bool:
+		return vv
+	case Amount:
+		return vv.Quantity.Sign() != 0
+	case string:
+		return vv != ""
+	}
+	return v != nil
+}
+
+func describe(v Value) string {
+	switch vv := v.(type) {
+	case Amount:
+		return fmt.Sprintf("amount %s", vv)
+	case string:
+		return fmt.Sprintf("string %q", vv)
+	case time.Time:
+		return fmt.Sprintf("date %s", vv.Format("2006/01/02"))
+	case bool:
+		return fmt.Sprintf("boolean %t", vv)
+	case *regexp.Regexp:
+		return fmt.Sprintf("regexp /%s/", vv)
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,183 @@
+package valexpr
+
+import (
+	"math/big"
+	"time"
+)
+
+// function is a built-in function. Arguments are evaluated before the
+// call.
+type function struct {
+	minArgs, maxArgs int
+	call             func(e *Expr, n *callNode, env *Env, args []Value) (Value, error)
+}
+
+var functions map[string]function
+
+func init() {
+	functions = map[string]function{
+		"abs":       {1, 1, callAbs},
+		"round":     {1, 2, callRound},
+		"floor":     {1, 1, callFloor},
+		"ceiling":   {1, 1, callCeiling},
+		"quantity":  {1, 1, callQuantity},
+		"commodity": {1, 1, callCommodity},
+		"market":    {1, 3, callMarket},
+	}
+}
+
+func (n *callNode) eval(e *Expr, env *Env) (Value, error) {
+	fn, ok := functions[n.name]
+	if !ok {
+		return nil, e.errorf(n, "unknown function %q", n.name)
+	}
+	if len(n.args) < fn.minArgs || len(n.args) > fn.maxArgs {
+		return nil, e.errorf(n, "wrong number of arguments to %s: got %d", n.name, len(n.args))
+	}
+	args := make([]Value, len(n.args))
+	for i, arg := range n.args {
+		v, err := arg.eval(e, env)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	return fn.call(e, n, env, args)
+}
+
+func (e *Expr) amountArg(n *callNode, args []Value, i int) (Amount, error) {
+	a, ok := args[i].(Amount)
+	if !ok {
+		return Amount{}, e.errorf(n.args[i], "%s expects an amount, got %s", n.name, describe(args[i]))
+	}
+	return a, nil
+}
+
+func callAbs(e *Expr, n *callNode, env *Env, args []Value) (Value, error) {
+	a, err := e.amountArg(n, args, 0)
+	if err != nil {
+		return nil, err
+	}
+	a.Quantity.Abs(a.Quantity)
+	return a, nil
+}
+
+// callRound rounds half away from zero, to the given number of decimals
+// or to the commodity's precision.
+func callRound(e *Expr, n *callNode, env *Env, args []Value) (Value, error) {
+	a, err := e.amountArg(n, args, 0)
+	if err != nil {
+		return nil, err
+	}
+	decimals := env.precision(a.Commodity)
+	if len(args) == 2 {
+		d, err := e.amountArg(n, args, 1)
+		if err != nil {
+			return nil, err
+		}
+		if !d.Quantity.IsInt() || d.Quantity.Num().BitLen() > 16 {
+			return nil, e.errorf(n.args[1], "round expects a small whole number of decimals, got %s", d)
+		}
+		decimals = int(d.Quantity.Num().Int64())
+	}
+	scale := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(decimals))), nil))
+	if decimals < 0 {
+		scale.Inv(scale)
+	}
+	q := new(big.Rat).Mul(a.Quantity, scale)
+	half := big.NewRat(1, 2)
+	if q.Sign() < 0 {
+		half.Neg(half)
+	}
+	q.Add(q, half)
+	a.Quantity.SetInt(truncate(q)).Quo(a.Quantity, scale)
+	return a, nil
+}
+
+func callFloor(e *Expr, n *callNode, env *Env, args []Value) (Value, error) {
+	a, err := e.amountArg(n, args, 0)
+	if err != nil {
+		return nil, err
+	}
+	i := truncate(a.Quantity)
+	if a.Quantity.Sign() < 0 && !a.Quantity.IsInt() {
+		i.Sub(i, big.NewInt(1))
+	}
+	a.Quantity.SetInt(i)
+	return a, nil
+}
+
+func callCeiling(e *Expr, n *callNode, env *Env, args []Value) (Value, error) {
+	a, err := e.amountArg(n, args, 0)
+	if err != nil {
+		return nil, err
+	}
+	i := truncate(a.Quantity)
+	if a.Quantity.Sign() > 0 && !a.Quantity.IsInt() {
+		i.Add(i, big.NewInt(1))
+	}
+	a.Quantity.SetInt(i)
+	return a, nil
+}
+
+func callQuantity(e *Expr, n *callNode, env *Env, args []Value) (Value, error) {
+	a, err := e.amountArg(n, args, 0)
+	if err != nil {
+		return nil, err
+	}
+	return Amount{a.Quantity, ""}, nil
+}
+
+func callCommodity(e *Expr, n *callNode, env *Env, args []Value) (Value, error) {
+	a, err := e.amountArg(n, args, 0)
+	if err != nil {
+		return nil, err
+	}
+	return a.Commodity, nil
+}
+
+// callMarket implements market(amount[, date[, commodity]]).  The date
+// defaults to the `date` variable, or today.
+func callMarket(e *Expr, n *callNode, env *Env, args []Value) (Value, error) {
+	a, err := e.amountArg(n, args, 0)
+	if err != nil {
+		return nil, err
+	}
+	at, ok := env.lookup("date")
+	if len(args) > 1 {
+		at, ok = args[1], true
+	}
+	if !ok {
+		at, _ = env.lookup("today")
+	}
+	date, ok := at.(time.Time)
+	if !ok {
+		return nil, e.errorf(n, "market expects a date, got %s", describe(at))
+	}
+	target := ""
+	if len(args) > 2 {
+		if target, ok = args[2].(string); !ok {
+			return nil, e.errorf(n.args[2], "market expects a commodity name, got %s", describe(args[2]))
+		}
+	}
+	if env == nil || env.Market == nil {
+		return a, nil
+	}
+	converted, err := env.Market(a, date, target)
+	if err != nil {
+		return nil, e.errorf(n, "%s", err)
+	}
+	return converted, nil
+}
+
+// truncate returns the integer part of q, rounding towards zero.
+func truncate(q *big.Rat) *big.Int {
+	return new(big.Int).Quo(q.Num(), q.Denom())
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
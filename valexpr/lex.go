@@ -0,0 +1,136 @@
+package valexpr
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenType int
+
+const (
+	tokEOF tokenType = iota
+	tokNumber
+	tokIdent
+	tokCommodity // symbol like "$", or a quoted commodity
+	tokString
+	tokDate
+	tokRegexp
+	tokOp // operators and punctuation
+)
+
+type token struct {
+	typ tokenType
+	pos int
+	val string
+}
+
+func (t token) String() string {
+	if t.typ == tokEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("%q", t.val)
+}
+
+// operators, longest first so that "==" is preferred over "=".
+var operators = []string{"==", "!=", "<=", ">=", "=~", "&&", "||", "+", "-", "*", "/", "(", ")", ",", "<", ">", "!", "&", "|", "?", ":"}
+
+// scanner splits an expression into tokens. Whether a '/' starts a
+// regexp or is a division depends on the parser's position, hence
+// the `operand` argument.
+type scanner struct {
+	input string
+	pos   int
+}
+
+func (s *scanner) errorf(pos int, format string, args ...interface{}) error {
+	return &Error{Expr: s.input, Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (s *scanner) skipSpaces() {
+	for s.pos < len(s.input) && (s.input[s.pos] == ' ' || s.input[s.pos] == '\t') {
+		s.pos++
+	}
+}
+
+// next scans the next token. When `operand` is true, a '/' starts a
+// regexp rather than a division.
+func (s *scanner) next(operand bool) (token, error) {
+	s.skipSpaces()
+	start := s.pos
+	if s.pos >= len(s.input) {
+		return token{tokEOF, start, ""}, nil
+	}
+
+	r, w := utf8.DecodeRuneInString(s.input[s.pos:])
+	switch {
+	case unicode.IsDigit(r) || (r == '.' && s.pos+1 < len(s.input) && isDigit(s.input[s.pos+1])):
+		for s.pos < len(s.input) && (isDigit(s.input[s.pos]) || s.input[s.pos] == '.' || s.isThousands()) {
+			s.pos++
+		}
+		return token{tokNumber, start, s.input[start:s.pos]}, nil
+	case r == '_' || unicode.IsLetter(r):
+		for s.pos < len(s.input) {
+			r, w := utf8.DecodeRuneInString(s.input[s.pos:])
+			if r != '_' && r != '.' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+				break
+			}
+			s.pos += w
+		}
+		return token{tokIdent, start, s.input[start:s.pos]}, nil
+	case r == '"' || r == '\'':
+		end := strings.IndexRune(s.input[s.pos+1:], r)
+		if end < 0 {
+			return token{}, s.errorf(start, "unterminated string")
+		}
+		s.pos += end + 2
+		return token{tokString, start, s.input[start+1 : s.pos-1]}, nil
+	case r == '[':
+		end := strings.IndexByte(s.input[s.pos:], ']')
+		if end < 0 {
+			return token{}, s.errorf(start, "unterminated date")
+		}
+		s.pos += end + 1
+		return token{tokDate, start, s.input[start+1 : s.pos-1]}, nil
+	case r == '/' && operand:
+		end := start + 1
+		for ; end < len(s.input) && s.input[end] != '/'; end++ {
+			if s.input[end] == '\\' {
+				end++
+			}
+		}
+		if end >= len(s.input) {
+			return token{}, s.errorf(start, "unterminated regexp")
+		}
+		s.pos = end + 1
+		return token{tokRegexp, start, s.input[start+1 : end]}, nil
+	}
+
+	for _, op := range operators {
+		if strings.HasPrefix(s.input[s.pos:], op) {
+			s.pos += len(op)
+			return token{tokOp, start, op}, nil
+		}
+	}
+
+	if unicode.IsGraphic(r) && !unicode.IsSpace(r) {
+		s.pos += w
+		return token{tokCommodity, start, s.input[start:s.pos]}, nil
+	}
+	return token{}, s.errorf(start, "unexpected character %#U", r)
+}
+
+// isThousands tells if the scanner is on a thousands separator, as in
+// "1,000". Other commas separate function arguments.
+func (s *scanner) isThousands() bool {
+	rest := s.input[s.pos:]
+	if len(rest) < 4 || rest[0] != ',' || !isDigit(rest[1]) || !isDigit(rest[2]) || !isDigit(rest[3]) {
+		return false
+	}
+	return len(rest) == 4 || !isDigit(rest[4])
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
@@ -0,0 +1,392 @@
+package valexpr
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Error is a syntax or evaluation error in a value expression.
+type Error struct {
+	Expr string // The full expression.
+	Pos  int    // Byte offset of the problem in Expr.
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("value expression %q, at %d: %s", e.Expr, e.Pos, e.Msg)
+}
+
+// Expr is a parsed value expression, which can be evaluated many times.
+type Expr struct {
+	src  string
+	root node
+}
+
+func (e *Expr) String() string {
+	return e.src
+}
+
+// node is an element of the syntax tree of an expression.
+type node interface {
+	eval(e *Expr, env *Env) (Value, error)
+	position() int
+}
+
+type literalNode struct {
+	pos int
+	val Value
+}
+
+type variableNode struct {
+	pos  int
+	name string
+}
+
+type callNode struct {
+	pos  int
+	name string
+	args []node
+}
+
+type unaryNode struct {
+	pos int
+	op  string
+	x   node
+}
+
+type binaryNode struct {
+	pos  int
+	op   string
+	x, y node
+}
+
+type ternaryNode struct {
+	pos        int
+	cond, x, y node
+}
+
+func (n *literalNode) position() int  { return n.pos }
+func (n *variableNode) position() int { return n.pos }
+func (n *callNode) position() int     { return n.pos }
+func (n *unaryNode) position() int    { return n.pos }
+func (n *binaryNode) position() int   { return n.pos }
+func (n *ternaryNode) position() int  { return n.pos }
+
+// Parse parses a value expression.
+//
+//	expr:    or ("?" expr ":" expr)?
+//	or:      and (("|" | "||" | "or") and)*
+//	and:     compare (("&" | "&&" | "and") compare)*
+//	compare: sum (("==" | "!=" | "<" | "<=" | ">" | ">=" | "=~") sum)?
+//	sum:     product (("+" | "-") product)*
+//	product: unary (("*" | "/") unary)*
+//	unary:   ("-" | "!" | "not") unary | primary
+//	primary: amount | "string" | [date] | /regexp/ | "true" | "false" |
+//	         name | name "(" (expr ("," expr)*)? ")" | "(" expr ")"
+//	amount:  number commodity? | commodity number
+func Parse(expr string) (*Expr, error) {
+	p := &parser{s: scanner{input: expr}}
+	if err := p.advance(true); err != nil {
+		return nil, err
+	}
+	root, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.typ != tokEOF {
+		return nil, p.errorf("unexpected %s", p.tok)
+	}
+	return &Expr{src: expr, root: root}, nil
+}
+
+type parser struct {
+	s   scanner
+	tok token
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return p.s.errorf(p.tok.pos, format, args...)
+}
+
+// advance moves on to the next token. `operand` tells if an operand
+// is expected next, see scanner.next.
+func (p *parser) advance(operand bool) error {
+	tok, err := p.s.next(operand)
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+// peek returns the token following the current one, without consuming
+// it.
+func (p *parser) peek() token {
+	pos := p.s.pos
+	tok, _ := p.s.next(false)
+	p.s.pos = pos
+	return tok
+}
+
+func (p *parser) isOp(ops ...string) bool {
+	if p.tok.typ != tokOp && p.tok.typ != tokIdent {
+		return false
+	}
+	for _, op := range ops {
+		if p.tok.val == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) parseExpr() (node, error) {
+	cond, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if !p.isOp("?") {
+		return cond, nil
+	}
+	pos := p.tok.pos
+	if err := p.advance(true); err != nil {
+		return nil, err
+	}
+	x, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isOp(":") {
+		return nil, p.errorf("expected ':' in conditional, got %s", p.tok)
+	}
+	if err := p.advance(true); err != nil {
+		return nil, err
+	}
+	y, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	return &ternaryNode{pos, cond, x, y}, nil
+}
+
+// binaryLevels lists the binary operators, by increasing precedence.
+var binaryLevels = [][]string{
+	{"|", "||", "or"},
+	{"&", "&&", "and"},
+	{"==", "!=", "<", "<=", ">", ">=", "=~"},
+	{"+", "-"},
+	{"*", "/"},
+}
+
+var normalizedOps = map[string]string{
+	"||":  "|",
+	"or":  "|",
+	"&&":  "&",
+	"and": "&",
+	"not": "!",
+}
+
+func normalizeOp(op string) string {
+	if norm, ok := normalizedOps[op]; ok {
+		return norm
+	}
+	return op
+}
+
+func (p *parser) parseBinary(level int) (node, error) {
+	if level == len(binaryLevels) {
+		return p.parseUnary()
+	}
+
+	x, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp(binaryLevels[level]...) {
+		op, pos := normalizeOp(p.tok.val), p.tok.pos
+		if err := p.advance(true); err != nil {
+			return nil, err
+		}
+		y, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		x = &binaryNode{pos, op, x, y}
+		if binaryLevels[level][0] == "==" {
+			// Comparisons do not chain.
+			break
+		}
+	}
+	return x, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.isOp("-", "!", "not") {
+		op, pos := normalizeOp(p.tok.val), p.tok.pos
+		if err := p.advance(true); err != nil {
+			return nil, err
+		}
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{pos, op, x}, nil
+	}
+	return p.parsePrimary()
+}
+
+var keywords = map[string]bool{
+	"and":   true,
+	"or":    true,
+	"not":   true,
+	"true":  true,
+	"false": true,
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.tok
+	switch tok.typ {
+	case tokNumber:
+		q, err := p.number(tok)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.advance(false); err != nil {
+			return nil, err
+		}
+		commodity := ""
+		if p.tok.typ == tokCommodity || (p.tok.typ == tokIdent && !keywords[p.tok.val] && p.peek().val != "(") {
+			commodity = p.tok.val
+			if err := p.advance(false); err != nil {
+				return nil, err
+			}
+		}
+		return &literalNode{tok.pos, Amount{q, commodity}}, nil
+
+	case tokCommodity, tokIdent, tokString:
+		if next := p.peek(); next.typ == tokNumber || (next.val == "-" && tok.typ == tokCommodity) {
+			return p.parsePrefixedAmount()
+		}
+		if err := p.advance(false); err != nil {
+			return nil, err
+		}
+		switch {
+		case tok.typ == tokString:
+			return &literalNode{tok.pos, tok.val}, nil
+		case tok.typ == tokCommodity:
+			return nil, p.s.errorf(tok.pos, "expected a quantity after commodity %q", tok.val)
+		case tok.val == "true" || tok.val == "false":
+			return &literalNode{tok.pos, tok.val == "true"}, nil
+		case p.isOp("("):
+			return p.parseCall(tok)
+		}
+		return &variableNode{tok.pos, tok.val}, nil
+
+	case tokDate:
+		date := strings.NewReplacer("/", "-", ".", "-").Replace(strings.TrimSpace(tok.val))
+		t, err := time.ParseInLocation("2006-1-2", date, time.UTC)
+		if err != nil {
+			return nil, p.errorf("invalid date [%s]", tok.val)
+		}
+		if err := p.advance(false); err != nil {
+			return nil, err
+		}
+		return &literalNode{tok.pos, t}, nil
+
+	case tokRegexp:
+		re, err := regexp.Compile(tok.val)
+		if err != nil {
+			return nil, p.errorf("invalid regexp: %s", err)
+		}
+		if err := p.advance(false); err != nil {
+			return nil, err
+		}
+		return &literalNode{tok.pos, re}, nil
+
+	case tokOp:
+		if tok.val == "(" {
+			if err := p.advance(true); err != nil {
+				return nil, err
+			}
+			x, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if !p.isOp(")") {
+				return nil, p.errorf("expected ')', got %s", p.tok)
+			}
+			if err := p.advance(false); err != nil {
+				return nil, err
+			}
+			return x, nil
+		}
+	}
+	return nil, p.errorf("unexpected %s", tok)
+}
+
+// parsePrefixedAmount parses amounts with a leading commodity, like
+// "$10", "$-10", "USD 10" or "\"pine apples\" 10".
+func (p *parser) parsePrefixedAmount() (node, error) {
+	pos, commodity := p.tok.pos, p.tok.val
+	if err := p.advance(true); err != nil {
+		return nil, err
+	}
+	negative := false
+	if p.isOp("-") {
+		negative = true
+		if err := p.advance(true); err != nil {
+			return nil, err
+		}
+	}
+	if p.tok.typ != tokNumber {
+		return nil, p.errorf("expected a quantity after commodity %q, got %s", commodity, p.tok)
+	}
+	q, err := p.number(p.tok)
+	if err != nil {
+		return nil, err
+	}
+	if negative {
+		q.Neg(q)
+	}
+	if err := p.advance(false); err != nil {
+		return nil, err
+	}
+	return &literalNode{pos, Amount{q, commodity}}, nil
+}
+
+func (p *parser) parseCall(name token) (node, error) {
+	call := &callNode{pos: name.pos, name: name.val}
+	if err := p.advance(true); err != nil { // consume '('
+		return nil, err
+	}
+	for !p.isOp(")") {
+		if len(call.args) != 0 {
+			if !p.isOp(",") {
+				return nil, p.errorf("expected ',' or ')' in arguments to %s, got %s", name.val, p.tok)
+			}
+			if err := p.advance(true); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, arg)
+	}
+	if err := p.advance(false); err != nil {
+		return nil, err
+	}
+	return call, nil
+}
+
+func (p *parser) number(tok token) (*big.Rat, error) {
+	q, ok := new(big.Rat).SetString(strings.Replace(tok.val, ",", "", -1))
+	if !ok {
+		return nil, p.s.errorf(tok.pos, "invalid number %q", tok.val)
+	}
+	return q, nil
+}
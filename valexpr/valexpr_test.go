@@ -0,0 +1,97 @@
+package valexpr
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEval(t *testing.T) {
+	env := &Env{
+		Vars: map[string]Value{
+			"amount": Amount{big.NewRat(-1050, 100), "USD"},
+			"date":   time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC),
+			"payee":  "Grocery Store",
+		},
+		Market: func(a Amount, at time.Time, target string) (Amount, error) {
+			if a.Commodity != "EUR" || target != "USD" {
+				return Amount{}, fmt.Errorf("no price for %s in %q", a.Commodity, target)
+			}
+			return Amount{new(big.Rat).Mul(a.Quantity, big.NewRat(11, 10)), "USD"}, nil
+		},
+	}
+
+	tests := []struct {
+		in     string
+		expect string
+	}{
+		{"123 + 2 * 3 USD", "129 USD"},
+		{"$10 * 3", "30 $"},
+		{"$-10 + 2", "-8 $"},
+		{"EUR 10 / 4", "2.5 EUR"},
+		{"1,000.50 CAD - 0.50", "1000 CAD"},
+		{"-(2 + 3) * 2", "-10"},
+		{"10 USD / 4 USD", "2.5"},
+		{"abs(amount)", "10.5 USD"},
+		{"round(10.125 USD)", "10.13 USD"},
+		{"round(-10.125, 1)", "-10.1"},
+		{"floor(-1.5)", "-2"},
+		{"ceiling(1.2)", "2"},
+		{"quantity(amount) * 2", "-21"},
+		{"commodity(amount)", "USD"},
+		{"market(10 EUR, date, \"USD\")", "11 USD"},
+		{"amount < 0 ? -amount : amount", "10.5 USD"},
+		{"payee =~ /grocery/", "false"},
+		{"payee =~ /Grocery/ and date >= [2024/03/01]", "true"},
+		{"not (1 > 2) && \"a\" < \"b\"", "true"},
+		{"0 || \"fallback\"", "fallback"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			v, err := Eval(test.in, env)
+			require.NoError(t, err)
+			assert.Equal(t, test.expect, fmt.Sprint(v))
+		})
+	}
+}
+
+func TestEvalDoesNotModifyVariables(t *testing.T) {
+	amount := Amount{big.NewRat(5, 1), "USD"}
+	env := &Env{Vars: map[string]Value{"amount": amount}}
+
+	_, err := Eval("-amount", env)
+	require.NoError(t, err)
+	assert.Equal(t, "5 USD", amount.String())
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []struct {
+		in     string
+		expect string
+	}{
+		{"1 +", `value expression "1 +", at 3: unexpected end of expression`},
+		{"(1 + 2", `value expression "(1 + 2", at 6: expected ')', got end of expression`},
+		{"10 USD + 2 CAD", `value expression "10 USD + 2 CAD", at 7: mismatched commodities "USD" and "CAD"`},
+		{"10 USD * 2 USD", `value expression "10 USD * 2 USD", at 7: cannot multiply 10 USD by 2 USD`},
+		{"1 / 0", `value expression "1 / 0", at 2: division by zero`},
+		{"unknown + 1", `value expression "unknown + 1", at 0: unknown variable "unknown"`},
+		{"sqrt(4)", `value expression "sqrt(4)", at 0: unknown function "sqrt"`},
+		{"round()", `value expression "round()", at 0: wrong number of arguments to round: got 0`},
+		{"\"a\" - 1", `value expression "\"a\" - 1", at 4: cannot compute string "a" - amount 1`},
+		{"[2024/13/01]", `value expression "[2024/13/01]", at 0: invalid date [2024/13/01]`},
+		{"$", `value expression "$", at 0: expected a quantity after commodity "$"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			_, err := Eval(test.in, nil)
+			require.Error(t, err)
+			assert.Equal(t, test.expect, err.Error())
+		})
+	}
+}